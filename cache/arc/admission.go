@@ -0,0 +1,73 @@
+package arc
+
+import "errors"
+
+// ErrNotAdmitted is returned by TrySet when the admission filter turns away a key
+// it has not seen recently. The cache is left as it was, as if it were full.
+var ErrNotAdmitted = errors.New("arc: key not admitted")
+
+// AdmissionStats reports what an ARC's admission filter has decided.
+type AdmissionStats struct {
+	// Admitted is the number of keys let into T1 after being seen before.
+	Admitted int
+	// Rejected is the number of keys turned away on their first sighting.
+	Rejected int
+}
+
+// An admissionFilter is a doorkeeper: a Bloom filter of the keys recently
+// turned away. A key outside the cache directory is only admitted if the filter
+// has seen it, so a key set once, as by a scan, never reaches T1 or the disk.
+// The filter is emptied once it has turned away as many keys as the cache
+// directory holds, so that it only remembers recent sightings.
+type admissionFilter struct {
+	seen *bloomFilter
+	// Keys turned away since the filter was last emptied, and how many empty it.
+	rejected int
+	window   int
+}
+
+// newAdmissionFilter returns a filter for an ARC with room for limit entries,
+// which admits a key seen for the first time with probability fpRate.
+func newAdmissionFilter(limit int, fpRate float64) *admissionFilter {
+	var filter admissionFilter
+	filter.window = 2 * limit
+	filter.seen = newBloomFilter(filter.window, fpRate)
+	return &filter
+}
+
+// admit records a sighting of key, which is not in the cache directory,
+// and reports whether it has been seen before.
+func (filter *admissionFilter) admit(key string) bool {
+	if filter.seen.add(key) {
+		return true
+	}
+	filter.rejected++
+	if filter.rejected >= filter.window {
+		filter.seen.reset()
+		filter.rejected = 0
+	}
+	return false
+}
+
+// admit reports whether Set should add key, consulting the admission filter,
+// if there is one, for keys outside the cache directory.
+func (arc *ARC) admit(key string) bool {
+	if arc.admission == nil {
+		return true
+	}
+	if _, inCacheDirectory := arc.CheckCacheDirectory(key); inCacheDirectory {
+		return true
+	}
+	if !arc.admission.admit(key) {
+		arc.admissionStats.Rejected++
+		return false
+	}
+	arc.admissionStats.Admitted++
+	return true
+}
+
+// AdmissionStats returns statistics about the keys the ARC's admission filter
+// has admitted and rejected. They stay zero if it has none.
+func (arc *ARC) AdmissionStats() *AdmissionStats {
+	return &arc.admissionStats
+}
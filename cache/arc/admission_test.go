@@ -0,0 +1,117 @@
+package arc
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// Tests that an admission filter keeps keys set once out of T1 and off disk
+func TestARC_AdmissionFilter(t *testing.T) {
+	arc, err := NewARC(100, WithDirectory(t.TempDir()), WithAdmissionFilter(0.001))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	// The working set is set twice, so it is admitted the second time
+	for i := 0; i < 2; i++ {
+		for j := 0; j < 50; j++ {
+			arc.Set(fmt.Sprint("hot", j), []byte("v"))
+		}
+	}
+	if arc.Len() != 50 {
+		t.Fatalf("working set not admitted: %d", arc.Len())
+	}
+	writes := arc.DiskStats().Writes
+
+	// A scan of keys set once is turned away
+	for j := 0; j < 1000; j++ {
+		if arc.Set(fmt.Sprint("scan", j), []byte("v")) && j < 10 {
+			t.Fatalf("scan%d admitted", j)
+		}
+	}
+	stats := arc.AdmissionStats()
+	if stats.Admitted+stats.Rejected != 1100 || stats.Rejected < 1040 {
+		t.Fatalf("bad stats: %+v", stats)
+	}
+	if arc.DiskStats().Writes-writes != stats.Admitted-50 {
+		t.Fatalf("rejected keys written to disk")
+	}
+	for j := 0; j < 50; j++ {
+		if _, ok := arc.Get(fmt.Sprint("hot", j)); !ok {
+			t.Fatalf("hot%d pushed out by the scan", j)
+		}
+	}
+
+	// Keys already in the cache directory are not filtered
+	arc.Set("hot0", []byte("new"))
+	if value, _ := arc.CheckCache("hot0"); string(value) != "new" {
+		t.Fatalf("resident key not updated")
+	}
+	if arc.AdmissionStats().Admitted+arc.AdmissionStats().Rejected != 1100 {
+		t.Fatalf("resident key filtered")
+	}
+}
+
+// Tests that the false-positive rate of the filter is configurable
+func TestARC_AdmissionFilterRate(t *testing.T) {
+	for _, rate := range []float64{0.01, 0.2} {
+		arc, err := NewARC(1000, WithDirectory(t.TempDir()), WithAdmissionFilter(rate))
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		for j := 0; j < 2000; j++ {
+			arc.Set(fmt.Sprint("once", j), []byte("v"))
+		}
+		stats := arc.AdmissionStats()
+		observed := float64(stats.Admitted) / 2000
+		t.Logf("rate %.2f: %d of 2000 admitted", rate, stats.Admitted)
+		if observed > 2*rate {
+			t.Fatalf("rate %.2f: %.3f of keys seen once admitted", rate, observed)
+		}
+	}
+}
+
+// Tests that a rejected value still reaches the origin in WriteBack mode
+func TestARC_AdmissionFilterOrigin(t *testing.T) {
+	origin := newMapOrigin()
+	arc, err := NewARC(10, WithDirectory(t.TempDir()), WithOrigin(origin, WriteBack), WithAdmissionFilter(0.001))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if arc.Set("a", []byte("1")) {
+		t.Fatalf("first sighting admitted")
+	}
+	if !origin.has("a") || arc.OriginStats().Dirty != 0 {
+		t.Fatalf("rejected value not stored in the origin")
+	}
+	// Values read through from the origin are cached without consulting the filter
+	if value, ok := arc.Get("a"); !ok || string(value) != "1" {
+		t.Fatalf("bad read through: %q", value)
+	}
+}
+
+// Tests that TrySet tells a key turned away from a failed store
+func TestARC_TrySet(t *testing.T) {
+	origin := newMapOrigin()
+	arc, err := NewARC(10, WithDirectory(t.TempDir()), WithOrigin(origin, WriteThrough), WithAdmissionFilter(0.001))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := arc.TrySet("a", []byte("1"), 0); err != ErrNotAdmitted {
+		t.Fatalf("err: %v", err)
+	}
+	if err := arc.TrySet("a", []byte("1"), time.Hour); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, hasTTL, ok := arc.TTL("a"); !ok || !hasTTL {
+		t.Fatalf("binding or TTL not added")
+	}
+	origin.fail = true
+	if err := arc.TrySet("a", []byte("2"), 0); err == nil || err == ErrNotAdmitted {
+		t.Fatalf("err: %v", err)
+	}
+	// A key turned away whose value reached no origin is a failure too
+	if err := arc.TrySet("b", []byte("1"), 0); err == nil || err == ErrNotAdmitted {
+		t.Fatalf("err: %v", err)
+	}
+}
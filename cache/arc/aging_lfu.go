@@ -0,0 +1,217 @@
+package arc
+
+import (
+	"container/heap"
+)
+
+// An AgingLFU is a fixed-size in-memory cache with least-frequently-used eviction
+// and dynamic aging, and the same API as LRU. Each key has a priority of L + F,
+// where F is its number of uses and L, the cache's age, is the priority of the last
+// key evicted, so keys that were popular long ago are eventually evicted too.
+// This is LFU-DA, as Arlitt et al. describe it. GDSF, from the same paper and
+// Cherkasova's, divides F by the size of the value, so that one large value does not
+// take the place of many small ones. Among keys of equal priority, the least recently
+// used is evicted first. Operations take logarithmic time.
+type AgingLFU struct {
+	cache map[string]*agingEntry
+	queue agingQueue
+	// Priority of the last key evicted.
+	age float64
+	// Whether priorities account for the sizes of values, as in GDSF.
+	sizeAware bool
+	// Counts uses, to order keys of equal priority.
+	clock       uint64
+	usedEntries int
+	limit       int
+	stats       Stats
+}
+
+type agingEntry struct {
+	key      string
+	bytes    []byte
+	freq     int
+	priority float64
+	lastUse  uint64
+	index    int
+}
+
+// NewLFUDA returns a pointer to a new LFU-DA with a capacity to store limit entries.
+func NewLFUDA(limit int) *AgingLFU {
+	return newAgingLFU(limit, false)
+}
+
+// NewGDSF returns a pointer to a new GDSF with a capacity to store limit entries.
+// Values are weighed by their length in bytes, with empty values weighing one.
+func NewGDSF(limit int) *AgingLFU {
+	return newAgingLFU(limit, true)
+}
+
+func newAgingLFU(limit int, sizeAware bool) *AgingLFU {
+	var lfu AgingLFU
+	lfu.cache = make(map[string]*agingEntry)
+	lfu.sizeAware = sizeAware
+	lfu.limit = limit
+	return &lfu
+}
+
+// MaxEntries returns the maximum number of entries this AgingLFU can store
+func (lfu *AgingLFU) MaxEntries() int {
+	return lfu.limit
+}
+
+// RemainingSpaces returns the number of unused spaces for entries available in this AgingLFU
+func (lfu *AgingLFU) RemainingSpaces() int {
+	return lfu.limit - lfu.usedEntries
+}
+
+// Get returns the value associated with the given key, if it exists.
+// This operation counts as a "use" for that key-value pair
+// ok is true if a value was found and false otherwise.
+func (lfu *AgingLFU) Get(key string) (value []byte, ok bool) {
+	entry, found := lfu.cache[key]
+	if !found {
+		lfu.stats.Misses++
+		return nil, false
+	}
+	lfu.stats.Hits++
+	lfu.touch(entry)
+	return entry.bytes, true
+}
+
+// Check returns the value associated with the given key, if it exists.
+// This operation DOES NOT counts as a "use" for that key-value pair
+// ok is true if a value was found and false otherwise.
+func (lfu *AgingLFU) Check(key string) (value []byte, ok bool) {
+	if entry, found := lfu.cache[key]; found {
+		return entry.bytes, true
+	}
+	return nil, false
+}
+
+// Priority returns the priority of key, which is evicted when it is the lowest.
+func (lfu *AgingLFU) Priority(key string) (priority float64, ok bool) {
+	if entry, found := lfu.cache[key]; found {
+		return entry.priority, true
+	}
+	return 0, false
+}
+
+// Age returns the priority of the last key evicted, which new keys start from.
+func (lfu *AgingLFU) Age() float64 {
+	return lfu.age
+}
+
+// Remove removes and returns the value associated with the given key, if it exists.
+// ok is true if a value was found and false otherwise
+func (lfu *AgingLFU) Remove(key string) (value []byte, ok bool) {
+	entry, found := lfu.cache[key]
+	if !found {
+		return nil, false
+	}
+	heap.Remove(&lfu.queue, entry.index)
+	delete(lfu.cache, key)
+	lfu.usedEntries--
+	return entry.bytes, true
+}
+
+// Evict removes the binding with the lowest priority from the AgingLFU,
+// raising the age of the cache to its priority, and returns the key associated with it.
+func (lfu *AgingLFU) Evict() (key string, ok bool) {
+	if len(lfu.queue) == 0 {
+		return "", false
+	}
+	entry := heap.Pop(&lfu.queue).(*agingEntry)
+	lfu.age = entry.priority
+	delete(lfu.cache, entry.key)
+	lfu.usedEntries--
+	return entry.key, true
+}
+
+// Back returns the binding with the lowest priority, the one Evict would remove,
+// without removing it or counting it as a use.
+func (lfu *AgingLFU) Back() (key string, value []byte, ok bool) {
+	if len(lfu.queue) == 0 {
+		return "", nil, false
+	}
+	return lfu.queue[0].key, lfu.queue[0].bytes, true
+}
+
+// Set associates the given value with the given key, possibly evicting values
+// to make room. Returns true if the binding was added successfully, else false.
+// Replacing the value of a key counts as a use.
+func (lfu *AgingLFU) Set(key string, value []byte) bool {
+	if entry, found := lfu.cache[key]; found {
+		entry.bytes = value
+		lfu.touch(entry)
+		return true
+	}
+	if lfu.limit <= 0 {
+		return false
+	}
+	if lfu.RemainingSpaces() == 0 {
+		lfu.Evict()
+	}
+	entry := &agingEntry{key: key, bytes: value}
+	lfu.cache[key] = entry
+	lfu.usedEntries++
+	heap.Push(&lfu.queue, entry)
+	lfu.touch(entry)
+	return true
+}
+
+// touch counts a use of entry and recomputes its priority.
+func (lfu *AgingLFU) touch(entry *agingEntry) {
+	entry.freq++
+	lfu.clock++
+	entry.lastUse = lfu.clock
+	weight := float64(entry.freq)
+	if lfu.sizeAware {
+		weight /= float64(max(len(entry.bytes), 1))
+	}
+	entry.priority = lfu.age + weight
+	heap.Fix(&lfu.queue, entry.index)
+}
+
+// Len returns the number of bindings in the AgingLFU.
+func (lfu *AgingLFU) Len() int {
+	return lfu.usedEntries
+}
+
+// Stats returns statistics about how many search hits and misses have occurred.
+func (lfu *AgingLFU) Stats() *Stats {
+	return &lfu.stats
+}
+
+// agingQueue is a heap of entries, lowest priority first.
+type agingQueue []*agingEntry
+
+func (queue agingQueue) Len() int {
+	return len(queue)
+}
+
+func (queue agingQueue) Less(i, j int) bool {
+	if queue[i].priority != queue[j].priority {
+		return queue[i].priority < queue[j].priority
+	}
+	return queue[i].lastUse < queue[j].lastUse
+}
+
+func (queue agingQueue) Swap(i, j int) {
+	queue[i], queue[j] = queue[j], queue[i]
+	queue[i].index = i
+	queue[j].index = j
+}
+
+func (queue *agingQueue) Push(x any) {
+	entry := x.(*agingEntry)
+	entry.index = len(*queue)
+	*queue = append(*queue, entry)
+}
+
+func (queue *agingQueue) Pop() any {
+	old := *queue
+	entry := old[len(old)-1]
+	old[len(old)-1] = nil
+	*queue = old[:len(old)-1]
+	return entry
+}
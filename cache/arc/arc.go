@@ -1,10 +1,8 @@
 package arc
 
 import (
-	"fmt"
-	"os"
-	"path/filepath"
 	"errors"
+	"time"
 )
 
 // An ARC is a fixed-size in-memory cache with adaptive replacement eviction.
@@ -27,6 +25,28 @@ type ARC struct {
 	// This enables the algorithm to properly fetch B1 and B2's values
 	// if they are hit and need to be moved back into the cache.
 	cacheDirectory string
+	// disk reads and writes the files in cacheDirectory,
+	// or is nil for an ARC that only tracks keys. See withoutDisk.
+	disk *diskStore
+	// The store this ARC caches, or nil if there is none.
+	origin    Origin
+	writeMode WriteMode
+	// Keys in T1 or T2 whose values have not been stored in the origin yet.
+	dirty       map[string]bool
+	originStats OriginStats
+	// Deadlines of keys set with a TTL. See SetWithTTL.
+	expiry map[string]time.Time
+	// The filter consulted before adding new keys, or nil if there is none.
+	admission      *admissionFilter
+	admissionStats AdmissionStats
+	// Keys in T1 added by SetScan and not used since. See SetScan.
+	scanned map[string]bool
+	// The disk I/O held back by GetMany or SetMany, or nil outside of them.
+	batch *diskBatch
+	// Hits on ghosts by their depth in B1 and B2. See HitCurve.
+	curve *hitCurve
+	// Called, if set, with each key evicted from T1 or T2.
+	evicted func(key string)
 	// Target size of T1, which adapts depending on ghost list hits.
 	targetMarker int
 	// The maximum number of entries that can be added to the cache.
@@ -38,7 +58,7 @@ type ARC struct {
 }
 
 // NewARC returns a pointer to a new ARC with a capacity to store limited entries
-func NewARC(limit int) (*ARC, error) {
+func NewARC(limit int, opts ...Option) (*ARC, error) {
 	if limit <= 0 {
 		return nil, errors.New("Capacity must be greater than zero")
 	}
@@ -48,11 +68,26 @@ func NewARC(limit int) (*ARC, error) {
 	arc.b1List = NewLRU(limit)
 	arc.b2List = NewLRU(limit)
 	//arc.cache = make(map[string][]byte)
-	arc.cacheDirectory = "cache_directory"
-	// Make a new directory on disk that everyone can read/write to
-	os.Mkdir(arc.cacheDirectory, 0777)
+	conf := newConfig(opts)
+	arc.cacheDirectory = conf.dir
+	if !conf.noDisk {
+		disk, err := newDiskStore(arc.cacheDirectory, conf)
+		if err != nil {
+			return nil, err
+		}
+		arc.disk = disk
+	}
+	arc.origin = conf.origin
+	arc.writeMode = conf.writeMode
+	arc.dirty = make(map[string]bool)
+	arc.expiry = make(map[string]time.Time)
+	arc.scanned = make(map[string]bool)
+	if conf.admission {
+		arc.admission = newAdmissionFilter(limit, conf.admissionFPRate)
+	}
 	arc.targetMarker = 0
 	arc.limit = limit
+	arc.curve = newHitCurve(limit)
 	arc.stats = Stats{0, 0}
 	return &arc, nil
 }
@@ -67,14 +102,28 @@ func (arc *ARC) RemainingSpaces() int {
 	return (arc.limit - (arc.t1List.usedEntries + arc.t2List.usedEntries))
 }
 
+// ListLens returns the number of entries in each of T1, T2, B1 and B2.
+func (arc *ARC) ListLens() (t1, t2, b1, b2 int) {
+	return arc.t1List.Len(), arc.t2List.Len(), arc.b1List.Len(), arc.b2List.Len()
+}
+
+// TargetMarker returns the current target size of T1.
+func (arc *ARC) TargetMarker() int {
+	return arc.targetMarker
+}
+
 // Get returns the value associated with the given key, if it exists.
 // This operation counts as a "use" for that key-value pair
 // ok is true if a value was found and false otherwise.
 func (arc *ARC) Get(key string) (value []byte, ok bool) {
+	arc.dropIfExpired(key)
 	value, inCacheDirectory := arc.CheckCacheDirectory(key)
 
 	if inCacheDirectory {
 		_, inCache := arc.CheckCache(key)
+		if !inCache {
+			arc.curve.hit(key)
+		}
 		arc.Access(key)
 		if inCache {
 			arc.stats.Hits++
@@ -82,9 +131,9 @@ func (arc *ARC) Get(key string) (value []byte, ok bool) {
 			return value, ok
 		}
 	} else {
-		ok = false
 		arc.stats.Misses++
-		return nil, ok
+		// Read through to the origin, if there is one.
+		return arc.loadFromOrigin(key, arc.set)
 	}
 	return nil, false
 
@@ -135,9 +184,21 @@ func (arc *ARC) CheckCacheDirectory(key string) (value []byte, okcd bool) {
 }
 
 // Remove removes and returns the value associated with the given key, if it exists.
-// This erases the key-value pair from both the cache lists and the on-disk cache directory
+// This erases the key-value pair from both the cache lists and the on-disk cache directory,
+// and deletes it from the origin, if there is one, whether or not it was cached.
 // ok is true if a value was found and false otherwise
 func (arc *ARC) Remove(key string) (value []byte, ok bool) {
+	if arc.origin != nil {
+		delete(arc.dirty, key)
+		arc.deleteFromOrigin(key)
+	}
+	arc.dropIfExpired(key)
+	return arc.drop(key)
+}
+
+// drop removes key from whichever list holds it and from the on-disk cache directory,
+// returning its value if it was in the cache.
+func (arc *ARC) drop(key string) (value []byte, ok bool) {
 	value, found := arc.CheckCacheDirectory(key)
 
 	if !found {
@@ -159,11 +220,10 @@ func (arc *ARC) Remove(key string) (value []byte, ok bool) {
 		if _, found := arc.b2List.Check(key); found {
 			arc.b2List.Remove(key)
 		}
-		arc.RemoveFromDisk(key)
+		arc.forget(key)
 		//delete(arc.cache, key)
 	}
 	return value, ok
-
 }
 
 // Evict evicts an entry adaptively from either T1 or T2 (into B1 or B2),
@@ -175,8 +235,11 @@ func (arc *ARC) Evict(key string) {
 
 	// Evict from T1
 	if (arc.t1List.Len() > 0) && ((b2Hit && (t1Len == arc.targetMarker)) || (t1Len > arc.targetMarker)) {
-		evictedKey, ok := arc.t1List.Evict()
-		if ok {
+		evictedKey, ok := arc.evictFromCache(arc.t1List)
+		if ok && arc.scanned[evictedKey] {
+			// Keys added by a scan and never used leave no ghost.
+			arc.forget(evictedKey)
+		} else if ok {
 			// If adding an entry will violate B1 + B2 <= limit, Evict() clears a space
 			// from the appropriate ghost list.
 			if bLen == arc.limit {
@@ -184,14 +247,15 @@ func (arc *ARC) Evict(key string) {
 				if !gok {
 					ghostEvictedKey, _ = arc.b2List.Evict()
 				}
-				arc.RemoveFromDisk(ghostEvictedKey)
+				arc.forget(ghostEvictedKey)
 				//delete(arc.cache, ghostEvictedKey)
 			}
 			arc.b1List.Set(evictedKey, nil)
+			arc.curve.add(evictedKey)
 		}
 		// Evict from T2
 	} else {
-		evictedKey, ok := arc.t2List.Evict()
+		evictedKey, ok := arc.evictFromCache(arc.t2List)
 		if ok {
 			// If adding an entry will violate B1 + B2 <= c, Evict() clears a space
 			// from the appropriate ghost list.
@@ -200,10 +264,11 @@ func (arc *ARC) Evict(key string) {
 				if !gok {
 					ghostEvictedKey, _ = arc.b1List.Evict()
 				}
-				arc.RemoveFromDisk(ghostEvictedKey)
+				arc.forget(ghostEvictedKey)
 				//delete(arc.cache, ghostEvictedKey)
 			}
 			arc.b2List.Set(evictedKey, nil)
+			arc.curve.add(evictedKey)
 		}
 	}
 }
@@ -214,6 +279,7 @@ func (arc *ARC) Access(key string) {
 
 	// Case I: key is found in either T1 or T2
 	if value, found := arc.t1List.Check(key); found {
+		delete(arc.scanned, key)
 		arc.t1List.Remove(key)
 		arc.t2List.Set(key, value)
 		return
@@ -229,34 +295,48 @@ func (arc *ARC) Access(key string) {
 
 	// Case II: key is found in B1
 	if _, found := arc.b1List.Check(key); found {
+		// Fetch B1's value from the on-disk cache directory.
+		// A ghost whose file cannot be read back is dropped as a miss.
+		value, ok := arc.ReadFromDisk(key)
+		if !ok {
+			arc.b1List.Remove(key)
+			arc.forget(key)
+			return
+		}
 		// Adapt the target marker.
 		ratio := b2Len / b1Len
 		arc.targetMarker = min(arc.limit, arc.targetMarker+max(ratio, 1))
-		// Fetch B1's value from the on-disk cache directory.
-		value := arc.ReadFromDisk(key)
 		// Corner case: Evict might end up deleting key from the on-disk cache directory,
 		// if it is the least recently used entry in B1.
 		// Move key to the front of B1 to prevent this from happening.
 		arc.b1List.Set(key, nil)
 		arc.Evict(key)
 		arc.b1List.Remove(key)
+		arc.curve.remove(key)
 		// Add B1 back to the cache.
 		arc.t2List.Set(key, value)
 		return
 	}
 	// Case III: key is found in B2
 	if _, found := arc.b2List.Check(key); found {
+		// Fetch B2's value from the on-disk cache directory.
+		// A ghost whose file cannot be read back is dropped as a miss.
+		value, ok := arc.ReadFromDisk(key)
+		if !ok {
+			arc.b2List.Remove(key)
+			arc.forget(key)
+			return
+		}
 		// Adapt the target marker.
 		ratio := b1Len / b2Len
 		arc.targetMarker = max(0, arc.targetMarker-max(ratio, 1))
-		// Fetch B2's value from the on-disk cache directory.
-		value := arc.ReadFromDisk(key)
 		// Corner case: Evict might end up deleting key from the on-disk cache directory,
 		// if it is the least recently used entry in B2.
 		// Move key to the front of B2 to prevent this from happening.
 		arc.b2List.Set(key, nil)
 		arc.Evict(key)
 		arc.b2List.Remove(key)
+		arc.curve.remove(key)
 		// Add B2 back to the cache.
 		arc.t2List.Set(key, value)
 		return
@@ -266,51 +346,70 @@ func (arc *ARC) Access(key string) {
 
 // Set associates the given value with the given key, possibly evicting values
 // to make room. Returns true if the binding was added successfully, else false.
+// Any TTL previously set on the key is cleared.
+// In WriteThrough mode the value is stored in the origin first, and the binding
+// is not added if that fails. In WriteBack mode the binding is marked dirty.
+// With WithAdmissionFilter, the binding is not added if the key is new and has not
+// been seen recently; the value is then stored in the origin, if there is one, at once.
+// TrySet reports why a binding was not added.
 func (arc *ARC) Set(key string, value []byte) (ok bool) {
+	return arc.TrySet(key, value, 0) == nil
+}
 
-	t1Len := arc.t1List.Len()
-	b1Len := arc.b1List.Len()
-	t2Len := arc.t2List.Len()
-	b2Len := arc.b2List.Len()
-	l1Len := t1Len + b1Len
-	l2Len := t2Len + b2Len
-	totalLen := l1Len + l2Len
-	value, inCacheDirectory := arc.CheckCacheDirectory(key)
+// TrySet is like SetWithTTL, but reports why the binding was not added:
+// ErrNotAdmitted if the admission filter turned the key away, which is not a
+// failure, or the origin's error if storing the value there failed, which is done
+// first in WriteThrough mode and at once for a key turned away.
+func (arc *ARC) TrySet(key string, value []byte, ttl time.Duration) error {
+	arc.dropIfExpired(key)
+	if !arc.admit(key) {
+		if arc.origin != nil {
+			if err := arc.storeToOrigin(key, value); err != nil {
+				return err
+			}
+		}
+		return ErrNotAdmitted
+	}
+	if arc.origin != nil {
+		if arc.writeMode == WriteThrough {
+			if err := arc.storeToOrigin(key, value); err != nil {
+				return err
+			}
+		} else {
+			arc.dirty[key] = true
+		}
+	}
+	delete(arc.expiry, key)
+	if !arc.set(key, value) {
+		return errors.New("arc: unable to store value")
+	}
+	if ttl > 0 {
+		arc.expiry[key] = time.Now().Add(ttl)
+	}
+	return nil
+}
 
-	if inCacheDirectory {
+// set is Set without writing to the origin.
+func (arc *ARC) set(key string, value []byte) (ok bool) {
+
+	if _, inCacheDirectory := arc.CheckCacheDirectory(key); inCacheDirectory {
 		arc.Access(key)
 		// If key is in the cache directory, accessing it will move
-		// it to the front of T2 no matter what.
-		arc.t2List.Set(key, value)
-		return true
+		// it to the front of T2, unless it was a ghost whose value could
+		// not be read back from disk, in which case it is added as new.
+		if _, inCache := arc.CheckCache(key); inCache {
+			arc.t2List.Set(key, value)
+			// Keep the on-disk copy in step with the new value.
+			arc.WriteToDisk(key, value)
+			return true
+		}
 	}
 
+	_, inCacheDirectory := arc.CheckCacheDirectory(key)
+
 	// Case IV: key is not found
 	if !inCacheDirectory {
-		// Case (A): when L1 has exactly arc.limit number of pages
-		if l1Len == arc.limit {
-			if t1Len < arc.limit {
-				evictedKey, _ := arc.b1List.Evict()
-				arc.RemoveFromDisk(evictedKey)
-				//delete(arc.cache, evictedKey)
-				arc.Evict(key)
-			} else {
-				evictedKey, _ := arc.t1List.Evict()
-				arc.RemoveFromDisk(evictedKey)
-				//delete(arc.cache, evictedKey)
-			}
-		}
-
-		// Case (B): when L1 has less than arc.limit number of pages
-		if l1Len < arc.limit && totalLen >= arc.limit {
-			if totalLen == 2*arc.limit {
-				evictedKey, _ := arc.b2List.Evict()
-				arc.RemoveFromDisk(evictedKey)
-				//delete(arc.cache, evictedKey)
-			}
-			arc.Evict(key)
-		}
-
+		arc.makeRoom(key)
 		arc.t1List.Set(key, value)
 		// Add the key-value to the on-disk cache directory.
 		arc.WriteToDisk(key, value)
@@ -338,44 +437,138 @@ func (arc *ARC) Set(key string, value []byte) (ok bool) {
 
 }
 
+// makeRoom evicts entries and ghosts as needed to add key, which is not
+// in the cache directory, to T1.
+func (arc *ARC) makeRoom(key string) {
+	t1Len := arc.t1List.Len()
+	b1Len := arc.b1List.Len()
+	t2Len := arc.t2List.Len()
+	b2Len := arc.b2List.Len()
+	l1Len := t1Len + b1Len
+	l2Len := t2Len + b2Len
+	totalLen := l1Len + l2Len
+
+	// Case (A): when L1 has exactly arc.limit number of pages
+	if l1Len == arc.limit {
+		if t1Len < arc.limit {
+			evictedKey, _ := arc.b1List.Evict()
+			arc.forget(evictedKey)
+			//delete(arc.cache, evictedKey)
+			arc.Evict(key)
+		} else {
+			evictedKey, _ := arc.evictFromCache(arc.t1List)
+			arc.forget(evictedKey)
+			//delete(arc.cache, evictedKey)
+		}
+	}
+
+	// Case (B): when L1 has less than arc.limit number of pages
+	if l1Len < arc.limit && totalLen >= arc.limit {
+		if totalLen == 2*arc.limit {
+			evictedKey, _ := arc.b2List.Evict()
+			arc.forget(evictedKey)
+			//delete(arc.cache, evictedKey)
+		}
+		arc.Evict(key)
+	}
+}
+
 // WriteToDisk writes the key-value pair to a new file on disk.
-// The key is the name of the file and the file's contents are the value.
-func (arc *ARC) WriteToDisk(key string, value []byte) {
-	path := filepath.Join(arc.cacheDirectory, key)
-	file, err := os.Create(path)
-	if err != nil {
-		panic(err)
+// The key is the name of the file and the file's contents are the value,
+// compressed if the ARC was created WithCompression.
+// If the value does not fit in the disk quota, or cannot be written,
+// it is left off disk and the key cannot be recovered from a ghost list.
+func (arc *ARC) WriteToDisk(key string, value []byte) error {
+	if arc.batch != nil {
+		arc.batch.write(key, value)
+		return nil
+	}
+	if arc.disk == nil {
+		return nil
 	}
-	defer file.Close()
-	file.Write(value)
+	var makeRoom func() bool
+	if arc.disk.quotaPolicy == QuotaEvictGhosts {
+		makeRoom = arc.evictGhost
+	}
+	return arc.disk.write(key, value, makeRoom)
+}
+
+// evictGhost evicts the least recently used entry of the longer ghost list
+// to free disk space, and reports whether there was a ghost to evict.
+func (arc *ARC) evictGhost() bool {
+	ghosts := arc.b1List
+	if arc.b2List.Len() > arc.b1List.Len() {
+		ghosts = arc.b2List
+	}
+	evictedKey, ok := ghosts.Evict()
+	if ok {
+		arc.forget(evictedKey)
+		arc.disk.stats.QuotaEvictions++
+	}
+	return ok
 }
 
 // ReadFromDisk returns the value associated with a key.
 // The value is stored in the on-disk cache directory
-// in a file named the same as the key.
-func (arc *ARC) ReadFromDisk(key string) (value []byte) {
+// in a file named the same as the key, and is decompressed
+// with the codec recorded in the file's header.
+// ok is false if the key is not in the cache directory, or if its file
+// is missing, fails authentication or cannot be decoded.
+func (arc *ARC) ReadFromDisk(key string) (value []byte, ok bool) {
 	_, found := arc.CheckCacheDirectory(key)
-	if found {
-		path := filepath.Join(arc.cacheDirectory, key)
-		file, err := os.Open(path)
-		if err != nil {
-			panic(err)
-		}
-		defer file.Close()
-		file.Read(value)
-	} else {
-		fmt.Println("not in cache directory")
+	if !found {
+		return nil, false
+	}
+	if arc.disk == nil {
+		return nil, true
+	}
+	if value, ok := arc.batch.read(key); ok {
+		return value, true
+	}
+	value, err := arc.disk.read(key)
+	if err != nil {
+		return nil, false
 	}
-	return value
+	return value, true
 }
 
 // RemoveFromDisk deletes the file associated with a key
-// from the on-disk cache directory.
-func (arc *ARC) RemoveFromDisk(key string) {
-	path := filepath.Join(arc.cacheDirectory, key)
-	err := os.Remove(path)
-	if err != nil {
-		panic(err)
+// from the on-disk cache directory, if there is one.
+func (arc *ARC) RemoveFromDisk(key string) error {
+	if arc.disk == nil {
+		return nil
+	}
+	return arc.disk.remove(key)
+}
+
+// Purge removes every binding and ghost from the ARC and from the on-disk cache directory.
+// Dirty values are stored in the origin first; nothing is deleted from the origin.
+func (arc *ARC) Purge() {
+	arc.Flush()
+	for _, list := range []*LRU{arc.t1List, arc.t2List, arc.b1List, arc.b2List} {
+		for key := range list.cache {
+			arc.forget(key)
+		}
+	}
+	arc.t1List = NewLRU(arc.limit)
+	arc.t2List = NewLRU(arc.limit)
+	arc.b1List = NewLRU(arc.limit)
+	arc.b2List = NewLRU(arc.limit)
+	arc.dirty = make(map[string]bool)
+	arc.scanned = make(map[string]bool)
+	arc.targetMarker = 0
+	arc.resetCurve()
+}
+
+// forget drops what the ARC keeps about a key besides its list entries,
+// once the key has left the cache directory.
+func (arc *ARC) forget(key string) {
+	arc.RemoveFromDisk(key)
+	delete(arc.expiry, key)
+	delete(arc.scanned, key)
+	arc.curve.remove(key)
+	if arc.batch != nil {
+		arc.batch.forget(key)
 	}
 }
 
@@ -389,6 +582,11 @@ func (arc *ARC) Stats() *Stats {
 	return &arc.stats
 }
 
+// DiskStats returns statistics about the values written to the on-disk cache directory.
+func (arc *ARC) DiskStats() *DiskStats {
+	return &arc.disk.stats
+}
+
 // min returns the lesser of ints x and y.
 func min(x int, y int) int {
 	if x < y {
@@ -0,0 +1,171 @@
+package arc
+
+import "sort"
+
+// GetMany returns the values associated with the given keys, as calling Get
+// for each key in turn would, leaving out the keys that were not found.
+// The files of the keys in B1 or B2 are read ahead all at once,
+// and the values read through from the origin are written to disk at the end.
+func (arc *ARC) GetMany(keys []string) map[string][]byte {
+	arc.beginBatch(keys)
+	defer arc.endBatch()
+	values := make(map[string][]byte, len(keys))
+	for _, key := range keys {
+		if value, ok := arc.Get(key); ok {
+			values[key] = value
+		}
+	}
+	return values
+}
+
+// SetMany associates each value with its key, as calling Set for each key
+// in turn would, in sorted order. It returns the number of bindings added.
+// The values are written to the on-disk cache directory all at once at the end,
+// leaving out those whose keys left the cache directory during the batch.
+func (arc *ARC) SetMany(values map[string][]byte) (added int) {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	arc.beginBatch(keys)
+	defer arc.endBatch()
+	for _, key := range keys {
+		if arc.Set(key, values[key]) {
+			added++
+		}
+	}
+	return added
+}
+
+// RemoveMany removes the given keys, as calling Remove for each key in turn would,
+// and returns the values of those that were in the cache.
+func (arc *ARC) RemoveMany(keys []string) map[string][]byte {
+	values := make(map[string][]byte, len(keys))
+	for _, key := range keys {
+		if value, ok := arc.Remove(key); ok {
+			values[key] = value
+		}
+	}
+	return values
+}
+
+// A diskBatch holds back the disk I/O of a GetMany or SetMany,
+// so that it can be done for all the keys at once.
+type diskBatch struct {
+	// values holds the values read ahead for ghosts
+	// and those waiting to be written, by key.
+	values map[string][]byte
+	// writes marks the keys whose values are waiting to be written,
+	// and order lists them in the order they were first written.
+	writes map[string]bool
+	order  []string
+}
+
+// beginBatch holds back the ARC's disk I/O until endBatch,
+// reading ahead the files of the ghosts among keys.
+func (arc *ARC) beginBatch(keys []string) {
+	var batch diskBatch
+	batch.writes = make(map[string]bool)
+	var ghosts []string
+	for _, key := range keys {
+		if contains(arc.b1List, key) || contains(arc.b2List, key) {
+			ghosts = append(ghosts, key)
+		}
+	}
+	batch.values = arc.disk.readMany(ghosts)
+	arc.batch = &batch
+}
+
+// write holds back writing value under key.
+func (batch *diskBatch) write(key string, value []byte) {
+	if !batch.writes[key] {
+		batch.writes[key] = true
+		batch.order = append(batch.order, key)
+	}
+	batch.values[key] = value
+}
+
+// read returns the value read ahead or held back for key, if any.
+// A nil batch holds nothing.
+func (batch *diskBatch) read(key string) (value []byte, ok bool) {
+	if batch == nil {
+		return nil, false
+	}
+	value, ok = batch.values[key]
+	return value, ok
+}
+
+// forget drops what the batch holds for a key that has left the cache directory.
+func (batch *diskBatch) forget(key string) {
+	delete(batch.values, key)
+	delete(batch.writes, key)
+}
+
+// endBatch writes the values held back since beginBatch whose keys
+// are still in the cache directory.
+func (arc *ARC) endBatch() {
+	batch := arc.batch
+	arc.batch = nil
+	var keys []string
+	var values [][]byte
+	for _, key := range batch.order {
+		if batch.writes[key] {
+			// A key forgotten and written again is listed twice.
+			delete(batch.writes, key)
+			keys = append(keys, key)
+			values = append(values, batch.values[key])
+		}
+	}
+	if arc.disk.quota == 0 {
+		arc.disk.writeMany(keys, values)
+		return
+	}
+	// Making room in the quota may evict ghosts written earlier in the batch,
+	// so the values are written one at a time.
+	for i, key := range keys {
+		if _, found := arc.CheckCacheDirectory(key); found {
+			arc.WriteToDisk(key, values[i])
+		}
+	}
+}
+
+// GetMany returns the values associated with the given keys, as calling Get
+// for each key in turn would, leaving out the keys that were not found.
+func (lru *LRU) GetMany(keys []string) map[string][]byte {
+	values := make(map[string][]byte, len(keys))
+	for _, key := range keys {
+		if value, ok := lru.Get(key); ok {
+			values[key] = value
+		}
+	}
+	return values
+}
+
+// SetMany associates each value with its key, as calling Set for each key
+// in turn would, in sorted order. It returns the number of bindings added.
+func (lru *LRU) SetMany(values map[string][]byte) (added int) {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if lru.Set(key, values[key]) {
+			added++
+		}
+	}
+	return added
+}
+
+// RemoveMany removes the given keys, as calling Remove for each key in turn would,
+// and returns the values of those that were in the cache.
+func (lru *LRU) RemoveMany(keys []string) map[string][]byte {
+	values := make(map[string][]byte, len(keys))
+	for _, key := range keys {
+		if value, ok := lru.Remove(key); ok {
+			values[key] = value
+		}
+	}
+	return values
+}
@@ -0,0 +1,185 @@
+package arc
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+)
+
+// Tests that GetMany and SetMany leave an ARC as the single-key methods do
+func TestARC_BatchMatchesSingle(t *testing.T) {
+	single, err := NewARC(100, WithDirectory(t.TempDir()))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	batched, err := NewARC(100, WithDirectory(t.TempDir()))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	trace, _ := NewTrace("zipf", 20000, 500, 1)
+	for i := 0; i+10 <= len(trace); i += 10 {
+		keys := trace[i : i+10]
+		got := batched.GetMany(keys)
+		want := make(map[string][]byte)
+		for _, key := range keys {
+			if value, ok := single.Get(key); ok {
+				want[key] = value
+			}
+		}
+		missing := make(map[string][]byte)
+		for _, key := range keys {
+			if !bytes.Equal(got[key], want[key]) {
+				t.Fatalf("%s: got %q, want %q", key, got[key], want[key])
+			}
+			if _, ok := want[key]; !ok {
+				missing[key] = []byte(key)
+			}
+		}
+		sorted := make([]string, 0, len(missing))
+		for key := range missing {
+			sorted = append(sorted, key)
+		}
+		sort.Strings(sorted)
+		for _, key := range sorted {
+			single.Set(key, missing[key])
+		}
+		if added := batched.SetMany(missing); added != len(missing) {
+			t.Fatalf("added %d of %d", added, len(missing))
+		}
+		t1, t2, b1, b2 := single.ListLens()
+		if bt1, bt2, bb1, bb2 := batched.ListLens(); bt1 != t1 || bt2 != t2 || bb1 != b1 || bb2 != b2 {
+			t.Fatalf("lists differ: %d %d %d %d, want %d %d %d %d", bt1, bt2, bb1, bb2, t1, t2, b1, b2)
+		}
+	}
+	if *batched.Stats() != *single.Stats() || batched.TargetMarker() != single.TargetMarker() {
+		t.Fatalf("batched %+v, target %d; single %+v, target %d",
+			batched.Stats(), batched.TargetMarker(), single.Stats(), single.TargetMarker())
+	}
+	// The same files are on disk
+	if batched.DiskStats().UsedBytes != single.DiskStats().UsedBytes {
+		t.Fatalf("used %d bytes, want %d", batched.DiskStats().UsedBytes, single.DiskStats().UsedBytes)
+	}
+	t.Logf("writes: batched %d, single %d", batched.DiskStats().Writes, single.DiskStats().Writes)
+	if batched.DiskStats().Writes > single.DiskStats().Writes {
+		t.Fatalf("batches wrote more values")
+	}
+}
+
+// Tests that SetMany only writes the values whose keys are still in the cache directory
+func TestARC_SetManyWrites(t *testing.T) {
+	arc, err := NewARC(2, WithDirectory(t.TempDir()))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	values := make(map[string][]byte)
+	for _, key := range []string{"a", "b", "c", "d", "e"} {
+		values[key] = []byte(key)
+	}
+	// Keys are set in sorted order, and each pushes the oldest out of a full T1
+	if added := arc.SetMany(values); added != 5 {
+		t.Fatalf("added %d", added)
+	}
+	if _, ok := arc.CheckCache("d"); !ok || arc.Len() != 2 {
+		t.Fatalf("bad cache after SetMany")
+	}
+	if writes := arc.DiskStats().Writes; writes != 2 {
+		t.Fatalf("wrote %d values", writes)
+	}
+	if value, ok := arc.ReadFromDisk("e"); !ok || string(value) != "e" {
+		t.Fatalf("e not on disk")
+	}
+
+	removed := arc.RemoveMany([]string{"d", "e", "z"})
+	if len(removed) != 2 || string(removed["d"]) != "d" || arc.Len() != 0 {
+		t.Fatalf("bad remove: %q", removed)
+	}
+	if arc.DiskStats().UsedBytes != 0 {
+		t.Fatalf("removed values left on disk")
+	}
+}
+
+// Tests that GetMany brings ghosts back with the values read ahead from disk
+func TestARC_GetManyGhosts(t *testing.T) {
+	arc := newWorkingSetARC(t)
+	for i := 0; i < 20; i++ {
+		arc.Set(fmt.Sprint("t1-", i), []byte(fmt.Sprint("ghost", i)))
+	}
+	// The 20 keys set again are now in T2, and their ghosts
+	// are the next 20 keys of T1
+	keys := make([]string, 20)
+	for i := range keys {
+		keys[i] = fmt.Sprint("t1-", 20+i)
+	}
+	writes := arc.DiskStats().Writes
+	if values := arc.GetMany(keys); len(values) != 0 {
+		t.Fatalf("ghost hits returned values: %d", len(values))
+	}
+	for _, key := range keys {
+		if value, ok := arc.CheckCache(key); !ok || string(value) != "v" {
+			t.Fatalf("ghost %s not brought back: %q", key, value)
+		}
+	}
+	// Their values were already on disk
+	if arc.DiskStats().Writes != writes {
+		t.Fatalf("ghosts written again: %d", arc.DiskStats().Writes-writes)
+	}
+}
+
+// Tests that batches on a SyncARC are safe to run concurrently
+func TestSyncARC_Batch(t *testing.T) {
+	sarc, err := NewSyncARC(100, WithDirectory(t.TempDir()))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				values := make(map[string][]byte)
+				keys := make([]string, 0, 10)
+				for j := 0; j < 10; j++ {
+					key := fmt.Sprint((g*7 + i*3 + j) % 300)
+					keys = append(keys, key)
+					values[key] = []byte(key)
+				}
+				sarc.SetMany(values)
+				for key, value := range sarc.GetMany(keys) {
+					if string(value) != key {
+						t.Errorf("%s: got %q", key, value)
+						return
+					}
+				}
+				sarc.RemoveMany(keys[:2])
+			}
+		}(g)
+	}
+	wg.Wait()
+	if sarc.Len() > 100 {
+		t.Fatalf("over capacity: %d", sarc.Len())
+	}
+}
+
+// Tests the batch methods of an LRU
+func TestLRU_Batch(t *testing.T) {
+	lru := NewLRU(3)
+	added := lru.SetMany(map[string][]byte{"a": []byte("1"), "b": []byte("2"), "c": []byte("3"), "d": []byte("4")})
+	if added != 4 || lru.Len() != 3 {
+		t.Fatalf("bad SetMany: %d added, %d entries", added, lru.Len())
+	}
+	// Keys are set in sorted order, so a was evicted
+	values := lru.GetMany([]string{"a", "b", "d"})
+	if len(values) != 2 || string(values["b"]) != "2" || string(values["d"]) != "4" {
+		t.Fatalf("bad GetMany: %q", values)
+	}
+	if stats := lru.Stats(); stats.Hits != 2 || stats.Misses != 1 {
+		t.Fatalf("bad stats: %+v", stats)
+	}
+	removed := lru.RemoveMany([]string{"b", "c", "e"})
+	if len(removed) != 2 || lru.Len() != 1 {
+		t.Fatalf("bad RemoveMany: %q", removed)
+	}
+}
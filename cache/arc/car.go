@@ -0,0 +1,167 @@
+package arc
+
+import (
+	"container/list"
+	"sync/atomic"
+)
+
+// A ConcurrentHitPolicy is a Policy whose hits only set a flag on the key,
+// so that several goroutines may call OnHitConcurrent at once,
+// provided none of the Policy's other methods is running.
+// SyncPolicyCache serves hits on such policies under a read lock.
+type ConcurrentHitPolicy interface {
+	Policy
+	OnHitConcurrent(key string)
+}
+
+// carPolicy is CAR, Clock with Adaptive Replacement, as Bansal and Modha describe it.
+// T1 and T2 are CLOCK rings instead of LRU lists: a hit sets the key's reference bit
+// and moves nothing, and the hand clears bits and moves keys when making room.
+// B1 and B2 are ghost LRUs, and the target size of T1 adapts as in ARC.
+type carPolicy struct {
+	// The rings, with the hand at the front. Elements hold *carEntry.
+	t1Ring *list.List
+	t2Ring *list.List
+	// Resident keys, by key.
+	entries map[string]*carEntry
+	b1List  *LRU
+	b2List  *LRU
+	// Target size of T1.
+	targetMarker int
+	limit        int
+	// Keys evicted by the last OnInsert, waiting to be returned by Victim.
+	victims []string
+}
+
+// A carEntry is a resident key on one of the rings.
+type carEntry struct {
+	key     string
+	ref     int32
+	inT2    bool
+	element *list.Element
+}
+
+func newCARPolicy(limit int) Policy {
+	var policy carPolicy
+	policy.t1Ring = list.New()
+	policy.t2Ring = list.New()
+	policy.entries = make(map[string]*carEntry)
+	policy.b1List = NewLRU(limit)
+	policy.b2List = NewLRU(limit)
+	policy.limit = limit
+	return &policy
+}
+
+func (policy *carPolicy) OnHit(key string) {
+	policy.OnHitConcurrent(key)
+}
+
+// OnHitConcurrent sets the key's reference bit.
+func (policy *carPolicy) OnHitConcurrent(key string) {
+	if entry, ok := policy.entries[key]; ok && atomic.LoadInt32(&entry.ref) == 0 {
+		atomic.StoreInt32(&entry.ref, 1)
+	}
+}
+
+func (policy *carPolicy) OnMiss(key string) {}
+
+func (policy *carPolicy) OnInsert(key string) {
+	inB1, inB2 := contains(policy.b1List, key), contains(policy.b2List, key)
+	if policy.t1Ring.Len()+policy.t2Ring.Len() >= policy.limit {
+		policy.replace()
+		// Keep the directory to twice the cache, as ARC does.
+		if !inB1 && !inB2 {
+			if policy.t1Ring.Len()+policy.b1List.Len() >= policy.limit {
+				policy.b1List.Evict()
+			} else if policy.t1Ring.Len()+policy.t2Ring.Len()+policy.b1List.Len()+policy.b2List.Len() >= 2*policy.limit {
+				policy.b2List.Evict()
+			}
+		}
+	}
+	switch {
+	case inB1:
+		b1, b2 := policy.b1List.Len(), policy.b2List.Len()
+		policy.targetMarker = min(policy.limit, policy.targetMarker+max(b2/b1, 1))
+		policy.b1List.Remove(key)
+		policy.push(key, true)
+	case inB2:
+		b1, b2 := policy.b1List.Len(), policy.b2List.Len()
+		policy.targetMarker = max(0, policy.targetMarker-max(b1/b2, 1))
+		policy.b2List.Remove(key)
+		policy.push(key, true)
+	default:
+		policy.push(key, false)
+	}
+}
+
+// push adds key to the tail of T1 or T2, just behind the hand, with its bit clear.
+func (policy *carPolicy) push(key string, toT2 bool) {
+	entry := &carEntry{key: key, inT2: toT2}
+	if toT2 {
+		entry.element = policy.t2Ring.PushBack(entry)
+	} else {
+		entry.element = policy.t1Ring.PushBack(entry)
+	}
+	policy.entries[key] = entry
+}
+
+// replace turns the hands until a key with a clear reference bit is found,
+// demoting it to its ghost list. Referenced keys in T1 move to T2,
+// and referenced keys in T2 go round again, with their bits cleared.
+func (policy *carPolicy) replace() {
+	for {
+		if policy.t1Ring.Len() > 0 && policy.t1Ring.Len() >= max(1, policy.targetMarker) {
+			entry := policy.t1Ring.Front().Value.(*carEntry)
+			if atomic.LoadInt32(&entry.ref) == 0 {
+				policy.t1Ring.Remove(entry.element)
+				delete(policy.entries, entry.key)
+				policy.b1List.Set(entry.key, nil)
+				policy.victims = append(policy.victims, entry.key)
+				return
+			}
+			atomic.StoreInt32(&entry.ref, 0)
+			policy.t1Ring.Remove(entry.element)
+			entry.inT2 = true
+			entry.element = policy.t2Ring.PushBack(entry)
+			continue
+		}
+		if policy.t2Ring.Len() == 0 {
+			return
+		}
+		entry := policy.t2Ring.Front().Value.(*carEntry)
+		if atomic.LoadInt32(&entry.ref) == 0 {
+			policy.t2Ring.Remove(entry.element)
+			delete(policy.entries, entry.key)
+			policy.b2List.Set(entry.key, nil)
+			policy.victims = append(policy.victims, entry.key)
+			return
+		}
+		atomic.StoreInt32(&entry.ref, 0)
+		policy.t2Ring.MoveToBack(entry.element)
+	}
+}
+
+func (policy *carPolicy) Victim() (key string, ok bool) {
+	if len(policy.victims) == 0 {
+		policy.replace()
+	}
+	if len(policy.victims) == 0 {
+		return "", false
+	}
+	key = policy.victims[0]
+	policy.victims = policy.victims[1:]
+	return key, true
+}
+
+func (policy *carPolicy) Remove(key string) {
+	if entry, ok := policy.entries[key]; ok {
+		if entry.inT2 {
+			policy.t2Ring.Remove(entry.element)
+		} else {
+			policy.t1Ring.Remove(entry.element)
+		}
+		delete(policy.entries, key)
+	}
+	policy.b1List.Remove(key)
+	policy.b2List.Remove(key)
+}
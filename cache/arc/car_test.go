@@ -0,0 +1,98 @@
+package arc
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// Tests that CAR's hit ratio stays close to ARC's on the same traces
+func TestCAR_HitRatio(t *testing.T) {
+	for _, kind := range TraceKinds {
+		trace, err := NewTrace(kind, 100000, 10000, 1)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		for _, limit := range []int{100, 1000, 5000} {
+			arc, _ := Simulate("arc", limit, trace)
+			car, _ := Simulate("car", limit, trace)
+			lru, _ := Simulate("lru", limit, trace)
+			t.Logf("%-4s limit %4d: lru %.4f arc %.4f car %.4f", kind, limit, lru.HitRatio(), arc.HitRatio(), car.HitRatio())
+			if car.HitRatio() < arc.HitRatio()-0.02 {
+				t.Fatalf("%s limit %d: car %.4f well below arc %.4f", kind, limit, car.HitRatio(), arc.HitRatio())
+			}
+		}
+	}
+}
+
+// Tests that referenced keys survive the hand and unreferenced ones don't
+func TestCAR_Clock(t *testing.T) {
+	policy := newCARPolicy(3)
+	stats := SimulatePolicy(policy, 3, []string{"a", "b", "c", "a", "d", "a", "e", "a"})
+	if stats.Hits != 3 {
+		t.Fatalf("bad stats: %+v", stats)
+	}
+	car := policy.(*carPolicy)
+	if entry, ok := car.entries["a"]; !ok || !entry.inT2 {
+		t.Fatalf("referenced key not kept in t2")
+	}
+	// b and c were demoted, but b was trimmed to keep T1 and B1 within the limit
+	if len(car.entries) != 3 || car.b1List.Len() != 1 || !contains(car.b1List, "c") {
+		t.Fatalf("bad lists: %d resident, %d in b1", len(car.entries), car.b1List.Len())
+	}
+}
+
+// Tests concurrent hits and sets on a SyncPolicyCache under the race detector
+func TestSyncPolicyCache_Concurrent(t *testing.T) {
+	for _, name := range []string{"car", "clockpro", "s3fifo", "arc"} {
+		cache, err := NewSyncPolicyCache(name, 64)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		var wg sync.WaitGroup
+		for g := 0; g < 8; g++ {
+			wg.Add(1)
+			go func(g int) {
+				defer wg.Done()
+				for i := 0; i < 500; i++ {
+					key := fmt.Sprint((g*31 + i) % 100)
+					if value, ok := cache.Get(key); ok && string(value) != key {
+						t.Errorf("bad value for %s: %q", key, value)
+					} else if !ok {
+						cache.Set(key, []byte(key))
+					}
+				}
+			}(g)
+		}
+		wg.Wait()
+		if stats := cache.Stats(); stats.Hits+stats.Misses != 4000 || stats.Hits == 0 {
+			t.Fatalf("%s: bad stats %+v", name, stats)
+		}
+		if cache.Len() > 64 {
+			t.Fatalf("%s: len %d over limit", name, cache.Len())
+		}
+	}
+}
+
+// Compares parallel hits under the read lock of CAR, CLOCK-Pro and S3-FIFO with ARC's write lock
+func BenchmarkSyncPolicyCache_ParallelGet(b *testing.B) {
+	for _, name := range []string{"car", "clockpro", "s3fifo", "arc"} {
+		b.Run(name, func(b *testing.B) {
+			cache, err := NewSyncPolicyCache(name, 1024)
+			if err != nil {
+				b.Fatalf("err: %v", err)
+			}
+			for i := 0; i < 1024; i++ {
+				cache.Set(fmt.Sprint(i), []byte("v"))
+			}
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					cache.Get(fmt.Sprint(i % 1024))
+					i++
+				}
+			})
+		})
+	}
+}
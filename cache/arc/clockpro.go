@@ -0,0 +1,242 @@
+package arc
+
+import (
+	"container/list"
+	"sync/atomic"
+)
+
+// States of a page on the CLOCK-Pro clock.
+const (
+	// A hot page has a small reuse distance, like a LIR key in LIRS.
+	clockProHot = iota
+	// A cold page is resident, and in its test period until the hot hand passes it.
+	clockProCold
+	// A test page is a non-resident cold page still in its test period.
+	clockProTest
+)
+
+// clockProPolicy is CLOCK-Pro, as Jiang, Chen and Zhang describe it:
+// an approximation of LIRS at the cost of CLOCK. Hot, cold and test pages share
+// a single clock, which three hands turn. The cold hand evicts cold pages that
+// have not been used, turning them into test pages, and promotes those that have.
+// The hot hand demotes unused hot pages, and the test hand forgets old test pages.
+// The cold share of the cache adapts: a test page used again widens it,
+// and one forgotten without being used narrows it.
+// A hit only sets the page's reference bit, so it needs no list operations.
+type clockProPolicy struct {
+	// The clock, turning from front to back. Elements hold *clockProPage.
+	clock *list.List
+	pages map[string]*clockProPage
+	// The hands, or nil while the clock is empty.
+	handHot  *list.Element
+	handCold *list.Element
+	handTest *list.Element
+	// Number of pages in each state.
+	hots  int
+	colds int
+	tests int
+	// Target number of cold pages.
+	coldTarget int
+	limit      int
+	// Keys evicted by the last OnInsert, waiting to be returned by Victim.
+	victims []string
+}
+
+// A clockProPage is a key on the clock.
+type clockProPage struct {
+	key     string
+	state   int
+	ref     int32
+	element *list.Element
+}
+
+var _ ConcurrentHitPolicy = (*clockProPolicy)(nil)
+
+func newClockProPolicy(limit int) Policy {
+	var policy clockProPolicy
+	policy.clock = list.New()
+	policy.pages = make(map[string]*clockProPage)
+	policy.coldTarget = limit
+	policy.limit = limit
+	return &policy
+}
+
+func (policy *clockProPolicy) OnHit(key string) {
+	policy.OnHitConcurrent(key)
+}
+
+// OnHitConcurrent sets the page's reference bit.
+func (policy *clockProPolicy) OnHitConcurrent(key string) {
+	if page, ok := policy.pages[key]; ok && page.state != clockProTest && atomic.LoadInt32(&page.ref) == 0 {
+		atomic.StoreInt32(&page.ref, 1)
+	}
+}
+
+func (policy *clockProPolicy) OnMiss(key string) {}
+
+func (policy *clockProPolicy) OnInsert(key string) {
+	state := clockProCold
+	if page, ok := policy.pages[key]; ok && page.state == clockProTest {
+		// Used again in its test period: its reuse distance is small, so it comes back hot,
+		// and cold pages deserve more room.
+		policy.coldTarget = min(policy.coldTarget+1, policy.limit)
+		policy.remove(page)
+		state = clockProHot
+	}
+	for policy.hots+policy.colds >= policy.limit {
+		policy.evict()
+	}
+	page := &clockProPage{key: key, state: state}
+	if policy.handHot == nil {
+		page.element = policy.clock.PushBack(page)
+		policy.handHot, policy.handCold, policy.handTest = page.element, page.element, page.element
+	} else {
+		// The head of the clock, the last place the hot hand reaches.
+		page.element = policy.clock.InsertBefore(page, policy.handHot)
+	}
+	policy.pages[key] = page
+	if state == clockProHot {
+		policy.hots++
+		policy.balance()
+	} else {
+		policy.colds++
+	}
+}
+
+// next returns the element after e, going round the clock.
+func (policy *clockProPolicy) next(e *list.Element) *list.Element {
+	if e.Next() != nil {
+		return e.Next()
+	}
+	return policy.clock.Front()
+}
+
+// remove takes page off the clock, moving any hand that points to it on to the next page.
+func (policy *clockProPolicy) remove(page *clockProPage) {
+	e := page.element
+	next := policy.next(e)
+	if next == e {
+		next = nil
+	}
+	if policy.handHot == e {
+		policy.handHot = next
+	}
+	if policy.handCold == e {
+		policy.handCold = next
+	}
+	if policy.handTest == e {
+		policy.handTest = next
+	}
+	policy.clock.Remove(e)
+	delete(policy.pages, page.key)
+	switch page.state {
+	case clockProHot:
+		policy.hots--
+	case clockProCold:
+		policy.colds--
+	case clockProTest:
+		policy.tests--
+	}
+}
+
+// evict turns the cold hand until it evicts a cold page,
+// first demoting a hot page if there are no cold ones.
+func (policy *clockProPolicy) evict() {
+	for policy.colds == 0 {
+		policy.runHandHot()
+	}
+	for evicted := false; !evicted; {
+		evicted = policy.runHandCold()
+	}
+}
+
+// runHandCold moves the cold hand on by one page, reporting whether it evicted one.
+// A used cold page becomes hot; an unused one is evicted, and stays on the clock
+// as a test page until the test hand reaches it.
+func (policy *clockProPolicy) runHandCold() (evicted bool) {
+	e := policy.handCold
+	page := e.Value.(*clockProPage)
+	if page.state == clockProCold {
+		if atomic.LoadInt32(&page.ref) != 0 {
+			atomic.StoreInt32(&page.ref, 0)
+			page.state = clockProHot
+			policy.colds--
+			policy.hots++
+		} else {
+			page.state = clockProTest
+			policy.colds--
+			policy.tests++
+			policy.victims = append(policy.victims, page.key)
+			evicted = true
+			// Remember no more test pages than the cache holds.
+			for policy.tests > policy.limit {
+				policy.runHandTest()
+			}
+		}
+	}
+	if policy.handCold == e {
+		policy.handCold = policy.next(e)
+	}
+	policy.balance()
+	return evicted
+}
+
+// balance turns the hot hand until the hot pages fit in the room the cold ones leave.
+func (policy *clockProPolicy) balance() {
+	for policy.hots > max(policy.limit-policy.coldTarget, 0) {
+		policy.runHandHot()
+	}
+}
+
+// runHandHot moves the hot hand on by one page. A used hot page has its bit cleared,
+// and an unused one becomes cold. The test hand is pushed ahead of it.
+func (policy *clockProPolicy) runHandHot() {
+	if policy.handHot == policy.handTest {
+		policy.runHandTest()
+	}
+	e := policy.handHot
+	page := e.Value.(*clockProPage)
+	if page.state == clockProHot {
+		if atomic.LoadInt32(&page.ref) != 0 {
+			atomic.StoreInt32(&page.ref, 0)
+		} else {
+			page.state = clockProCold
+			policy.hots--
+			policy.colds++
+		}
+	}
+	if policy.handHot == e {
+		policy.handHot = policy.next(e)
+	}
+}
+
+// runHandTest moves the test hand on by one page, forgetting it if it is a test page.
+// A test page forgotten without being used means cold pages deserve less room.
+func (policy *clockProPolicy) runHandTest() {
+	e := policy.handTest
+	page := e.Value.(*clockProPage)
+	if page.state == clockProTest {
+		policy.remove(page)
+		policy.coldTarget = max(policy.coldTarget-1, 1)
+		return
+	}
+	policy.handTest = policy.next(e)
+}
+
+func (policy *clockProPolicy) Victim() (key string, ok bool) {
+	if len(policy.victims) == 0 && policy.hots+policy.colds > 0 {
+		policy.evict()
+	}
+	if len(policy.victims) == 0 {
+		return "", false
+	}
+	key = policy.victims[0]
+	policy.victims = policy.victims[1:]
+	return key, true
+}
+
+func (policy *clockProPolicy) Remove(key string) {
+	if page, ok := policy.pages[key]; ok {
+		policy.remove(page)
+	}
+}
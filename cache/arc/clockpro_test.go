@@ -0,0 +1,62 @@
+package arc
+
+import (
+	"testing"
+)
+
+// Tests the states of pages on the CLOCK-Pro clock
+func TestClockPro_States(t *testing.T) {
+	policy := newClockProPolicy(3).(*clockProPolicy)
+	state := func(key string) int {
+		page, ok := policy.pages[key]
+		if !ok {
+			return -1
+		}
+		return page.state
+	}
+	// a..c fill the cache as cold pages, and each new key evicts the oldest,
+	// until a fourth test page pushes a off the clock and narrows the cold share
+	SimulatePolicy(policy, 3, []string{"a", "b", "c", "d", "e", "f", "g", "e"})
+	if state("a") != -1 || state("b") != clockProTest || state("d") != clockProTest || state("e") != clockProCold {
+		t.Fatalf("bad states: a %d b %d d %d e %d", state("a"), state("b"), state("d"), state("e"))
+	}
+	if policy.coldTarget != 2 {
+		t.Fatalf("bad cold target: %d", policy.coldTarget)
+	}
+
+	// The cold hand promotes e, which was used, and evicts f, which was not
+	policy = newClockProPolicy(3).(*clockProPolicy)
+	SimulatePolicy(policy, 3, []string{"a", "b", "c", "d", "e", "f", "g", "e", "h"})
+	if state("e") != clockProHot || state("f") != clockProTest || state("b") != -1 {
+		t.Fatalf("bad states: e %d f %d b %d", state("e"), state("f"), state("b"))
+	}
+	if policy.hots+policy.colds != 3 || policy.tests > 3 {
+		t.Fatalf("bad counts: %d hot, %d cold, %d test", policy.hots, policy.colds, policy.tests)
+	}
+}
+
+// Tests that CLOCK-Pro approximates LIRS: it keeps much of a loop larger than the cache,
+// and keeps up with it and ARC on Zipf-heavy traces
+func TestClockPro_HitRatio(t *testing.T) {
+	trace, _ := NewTrace("loop", 100000, 1200, 1)
+	lirs, _ := Simulate("lirs", 1000, trace)
+	arc, _ := Simulate("arc", 1000, trace)
+	clockpro, _ := Simulate("clockpro", 1000, trace)
+	t.Logf("loop: arc %.4f lirs %.4f clockpro %.4f", arc.HitRatio(), lirs.HitRatio(), clockpro.HitRatio())
+	if clockpro.HitRatio() < 0.5 {
+		t.Fatalf("clockpro kept too little of the loop: %.4f", clockpro.HitRatio())
+	}
+
+	for _, kind := range []string{"zipf", "scan"} {
+		trace, _ := NewTrace(kind, 200000, 50000, 1)
+		for _, limit := range []int{100, 1000, 5000} {
+			lirs, _ := Simulate("lirs", limit, trace)
+			arc, _ := Simulate("arc", limit, trace)
+			clockpro, _ := Simulate("clockpro", limit, trace)
+			t.Logf("%s limit %4d: arc %.4f lirs %.4f clockpro %.4f", kind, limit, arc.HitRatio(), lirs.HitRatio(), clockpro.HitRatio())
+			if clockpro.HitRatio() < arc.HitRatio()-0.015 || clockpro.HitRatio() < lirs.HitRatio()-0.015 {
+				t.Fatalf("%s limit %d: clockpro %.4f", kind, limit, clockpro.HitRatio())
+			}
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package arc
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// A Codec compresses values before they are written to the on-disk cache
+// directory and restores them when they are read back.
+type Codec interface {
+	// ID identifies the codec in the header of every file it writes,
+	// so that a directory holding files from several codecs reads correctly.
+	// IDs below 16 are reserved for the codecs in this package.
+	ID() byte
+
+	// Compress returns the compressed form of src.
+	Compress(src []byte) ([]byte, error)
+
+	// Decompress reverses Compress.
+	Decompress(src []byte) ([]byte, error)
+}
+
+// Codec IDs used by this package. rawCodecID marks uncompressed values.
+const (
+	rawCodecID   byte = 0
+	flateCodecID byte = 1
+	gzipCodecID  byte = 2
+
+	reservedCodecIDs byte = 16
+)
+
+// codecsMu guards codecs, which RegisterCodec may change while caches read it.
+var codecsMu sync.RWMutex
+
+// codecs maps codec IDs to the codec able to decompress them.
+var codecs = map[byte]Codec{
+	flateCodecID: NewFlateCodec(flate.DefaultCompression),
+	gzipCodecID:  NewGzipCodec(gzip.DefaultCompression),
+}
+
+// RegisterCodec makes codec available for reading files from the on-disk cache
+// directory. Codecs in this package are registered already; a custom codec
+// must be registered before a cache is created with it, and before any cache
+// reads a file it wrote.
+func RegisterCodec(codec Codec) error {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	id := codec.ID()
+	if id < reservedCodecIDs {
+		return fmt.Errorf("codec ID %d is reserved", id)
+	}
+	if _, found := codecs[id]; found {
+		return fmt.Errorf("codec ID %d is already registered", id)
+	}
+	codecs[id] = codec
+	return nil
+}
+
+// lookupCodec returns the codec registered under id.
+func lookupCodec(id byte) (Codec, error) {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	codec, found := codecs[id]
+	if !found {
+		return nil, fmt.Errorf("unknown codec ID %d", id)
+	}
+	return codec, nil
+}
+
+type flateCodec struct {
+	level int
+}
+
+// NewFlateCodec returns a Codec using DEFLATE at the given compress/flate level.
+func NewFlateCodec(level int) Codec {
+	return flateCodec{level}
+}
+
+func (codec flateCodec) ID() byte {
+	return flateCodecID
+}
+
+func (codec flateCodec) Compress(src []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	writer, err := flate.NewWriter(&buf, codec.level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := writer.Write(src); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (codec flateCodec) Decompress(src []byte) ([]byte, error) {
+	reader := flate.NewReader(bytes.NewReader(src))
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+type gzipCodec struct {
+	level int
+}
+
+// NewGzipCodec returns a Codec using gzip at the given compress/gzip level.
+func NewGzipCodec(level int) Codec {
+	return gzipCodec{level}
+}
+
+func (codec gzipCodec) ID() byte {
+	return gzipCodecID
+}
+
+func (codec gzipCodec) Compress(src []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	writer, err := gzip.NewWriterLevel(&buf, codec.level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := writer.Write(src); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (codec gzipCodec) Decompress(src []byte) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
@@ -0,0 +1,83 @@
+package arc
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+// errTampered is returned when a file fails authentication.
+var errTampered = errors.New("cache file failed authentication")
+
+// A keyring holds the AES-GCM keys for encrypting values at rest.
+// New files are sealed with the current key; files sealed with any key
+// in the ring can still be opened, which allows keys to be rotated.
+type keyring struct {
+	currentID string
+	aeads     map[string]cipher.AEAD
+}
+
+// newKeyring returns a keyring sealing with the key named currentID.
+// keys maps key IDs to 16, 24 or 32 byte AES keys.
+func newKeyring(currentID string, keys map[string][]byte) (*keyring, error) {
+	if _, found := keys[currentID]; !found {
+		return nil, fmt.Errorf("no key with ID %q", currentID)
+	}
+	var ring keyring
+	ring.currentID = currentID
+	ring.aeads = make(map[string]cipher.AEAD)
+	for id, key := range keys {
+		if len(id) > 255 {
+			return nil, fmt.Errorf("key ID %q is longer than 255 bytes", id)
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %v", id, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		ring.aeads[id] = aead
+	}
+	return &ring, nil
+}
+
+// seal encrypts plaintext with the current key and appends
+// the key ID, nonce and ciphertext to dst.
+// additional is authenticated but not stored.
+func (ring *keyring) seal(dst, plaintext, additional []byte) ([]byte, error) {
+	aead := ring.aeads[ring.currentID]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	dst = append(dst, byte(len(ring.currentID)))
+	dst = append(dst, ring.currentID...)
+	dst = append(dst, nonce...)
+	return aead.Seal(dst, nonce, plaintext, additional), nil
+}
+
+// open reverses seal, returning the plaintext of sealed.
+func (ring *keyring) open(sealed, additional []byte) ([]byte, error) {
+	if len(sealed) < 1 || len(sealed) < 1+int(sealed[0]) {
+		return nil, errTampered
+	}
+	id := string(sealed[1 : 1+sealed[0]])
+	sealed = sealed[1+len(id):]
+	aead, found := ring.aeads[id]
+	if !found {
+		return nil, fmt.Errorf("no key with ID %q", id)
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errTampered
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, additional)
+	if err != nil {
+		return nil, errTampered
+	}
+	return plaintext, nil
+}
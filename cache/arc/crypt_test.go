@@ -0,0 +1,123 @@
+package arc
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var (
+	testKey1 = bytes.Repeat([]byte{1}, 32)
+	testKey2 = bytes.Repeat([]byte{2}, 16)
+)
+
+// Tests that encrypted values round-trip and never reach disk in the clear
+func TestARC_EncryptedGhostRecovery(t *testing.T) {
+	l, err := NewARC(2, WithEncryption("k1", testKey1))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	secret := []byte("user secret value")
+	l.Set("small", []byte("tiny"))
+	l.Get("small")
+	l.Set("secret", secret)
+	// Evicts "secret" into B1
+	l.Set("other", []byte("other"))
+
+	data, err := os.ReadFile(filepath.Join(l.cacheDirectory, "secret"))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if bytes.Contains(data, secret) {
+		t.Fatalf("value stored in the clear")
+	}
+	info, err := os.Stat(filepath.Join(l.cacheDirectory, "secret"))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if mode := info.Mode().Perm(); mode&0077 != 0 {
+		t.Fatalf("file readable by others: %v", mode)
+	}
+
+	l.Get("secret")
+	value, ok := l.Get("secret")
+	if !ok || !bytes.Equal(value, secret) {
+		t.Fatalf("bad recovered value: %q", value)
+	}
+	absolutePath, _ := filepath.Abs("./" + l.cacheDirectory)
+	os.RemoveAll(absolutePath)
+}
+
+// Tests that a tampered file is rejected as a miss
+func TestARC_EncryptedTampered(t *testing.T) {
+	l, err := NewARC(2, WithEncryption("k1", testKey1))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Set("small", []byte("tiny"))
+	l.Get("small")
+	l.Set("secret", []byte("user secret value"))
+	l.Set("other", []byte("other"))
+
+	path := filepath.Join(l.cacheDirectory, "secret")
+	data, _ := os.ReadFile(path)
+	data[len(data)-1] ^= 0xff
+	os.WriteFile(path, data, 0600)
+
+	if _, ok := l.ReadFromDisk("secret"); ok {
+		t.Fatalf("tampered file accepted")
+	}
+	l.Get("secret")
+	if _, ok := l.Get("secret"); ok {
+		t.Fatalf("tampered file should be a miss")
+	}
+	if _, found := l.CheckCacheDirectory("secret"); found {
+		t.Fatalf("tampered ghost should be dropped")
+	}
+	if n := l.DiskStats().Rejected; n != 2 {
+		t.Fatalf("bad rejected count: %d", n)
+	}
+
+	// A plain file swapped in for an encrypted one is rejected too
+	l.t1List.Set("plain", nil)
+	os.WriteFile(filepath.Join(l.cacheDirectory, "plain"), []byte("forged"), 0600)
+	if _, ok := l.ReadFromDisk("plain"); ok {
+		t.Fatalf("unencrypted file accepted")
+	}
+	absolutePath, _ := filepath.Abs("./" + l.cacheDirectory)
+	os.RemoveAll(absolutePath)
+}
+
+// Tests that files sealed with a retired key can be read after rotation
+func TestARC_EncryptionKeyRotation(t *testing.T) {
+	old, err := NewARC(2, WithEncryption("k1", testKey1))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	old.Set("key", []byte("sealed with k1"))
+
+	l, err := NewARC(2, WithEncryption("k2", testKey2))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.t1List.Set("key", nil)
+	if _, ok := l.ReadFromDisk("key"); ok {
+		t.Fatalf("read without the sealing key")
+	}
+
+	l, err = NewARC(2, WithEncryption("k2", testKey2), WithDecryptionKey("k1", testKey1))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.t1List.Set("key", nil)
+	if value, ok := l.ReadFromDisk("key"); !ok || string(value) != "sealed with k1" {
+		t.Fatalf("bad value after rotation: %q", value)
+	}
+
+	if _, err := NewARC(2, WithEncryption("short", []byte("short"))); err == nil {
+		t.Fatalf("invalid key accepted")
+	}
+	absolutePath, _ := filepath.Abs("./" + l.cacheDirectory)
+	os.RemoveAll(absolutePath)
+}
@@ -0,0 +1,141 @@
+package arc
+
+import "sort"
+
+// A CurvePoint is an estimate of the hit ratio an ARC would have had with room for Entries.
+type CurvePoint struct {
+	Entries  int
+	HitRatio float64
+}
+
+// A hitCurve records the hits on an ARC's ghosts by their depth, the number of
+// ghosts in B1 or B2 evicted from the cache more recently. A key found at depth d
+// was evicted by a cache of limit entries, but a cache of more than limit+d entries
+// would, as an LRU stack does, still have held it.
+type hitCurve struct {
+	// stamps holds the eviction stamp of every ghost, later evictions having
+	// higher stamps, and live counts the ghosts by stamp in a Fenwick tree.
+	stamps map[string]int
+	live   []int
+	next   int
+	// hits counts the ghost hits by depth, and total is their sum.
+	hits  []int
+	total int
+	// baseHits and baseMisses are the ARC's Hits and Misses when the curve started.
+	baseHits, baseMisses int
+}
+
+// newHitCurve returns a curve for an ARC with room for limit entries,
+// which has at most limit ghosts.
+func newHitCurve(limit int) *hitCurve {
+	var curve hitCurve
+	curve.stamps = make(map[string]int)
+	// Stamps are handed out up to four times the number of ghosts,
+	// then those of the ghosts left are renumbered from 1.
+	curve.live = make([]int, 4*limit+1)
+	curve.next = 1
+	curve.hits = make([]int, limit)
+	return &curve
+}
+
+// add stamps key, which has just been evicted into a ghost list.
+func (curve *hitCurve) add(key string) {
+	curve.remove(key)
+	if curve.next == len(curve.live) {
+		curve.renumber()
+	}
+	curve.stamps[key] = curve.next
+	curve.update(curve.next, 1)
+	curve.next++
+}
+
+// remove forgets key, which has left the ghost lists, if it was a ghost.
+func (curve *hitCurve) remove(key string) {
+	if stamp, found := curve.stamps[key]; found {
+		curve.update(stamp, -1)
+		delete(curve.stamps, key)
+	}
+}
+
+// hit records a hit on key, if it is a ghost, at its depth.
+func (curve *hitCurve) hit(key string) {
+	stamp, found := curve.stamps[key]
+	if !found {
+		return
+	}
+	depth := len(curve.stamps) - curve.count(stamp)
+	curve.hits[min(depth, len(curve.hits)-1)]++
+	curve.total++
+}
+
+// renumber gives the ghosts the stamps 1 to n, in the order of their stamps.
+func (curve *hitCurve) renumber() {
+	keys := make([]string, 0, len(curve.stamps))
+	for key := range curve.stamps {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return curve.stamps[keys[i]] < curve.stamps[keys[j]] })
+	for i := range curve.live {
+		curve.live[i] = 0
+	}
+	for i, key := range keys {
+		curve.stamps[key] = i + 1
+		curve.update(i+1, 1)
+	}
+	curve.next = len(keys) + 1
+}
+
+// update adds delta to the count of ghosts with the given stamp.
+func (curve *hitCurve) update(stamp int, delta int) {
+	for i := stamp; i < len(curve.live); i += i & -i {
+		curve.live[i] += delta
+	}
+}
+
+// count returns the number of ghosts with stamps up to stamp.
+func (curve *hitCurve) count(stamp int) (n int) {
+	for i := stamp; i > 0; i -= i & -i {
+		n += curve.live[i]
+	}
+	return n
+}
+
+// HitCurve estimates the hit ratio of Get had the ARC had room for more entries,
+// at n sizes spread evenly from MaxEntries to twice MaxEntries. The first point is
+// the hit ratio observed. A ghost found by Get at depth d, with d ghosts evicted
+// more recently, counts as a hit for every size above MaxEntries+d, as it would
+// for an LRU; the estimate is best for sizes close to MaxEntries.
+// Lookups are counted from the creation of the ARC, or its last Resize or Purge.
+func (arc *ARC) HitCurve(n int) []CurvePoint {
+	n = max(n, 2)
+	hits := arc.stats.Hits - arc.curve.baseHits
+	lookups := hits + arc.stats.Misses - arc.curve.baseMisses + arc.curve.total
+	points := make([]CurvePoint, n)
+	depth := 0
+	for i := range points {
+		entries := arc.limit + i*arc.limit/(n-1)
+		for ; depth < entries-arc.limit && depth < len(arc.curve.hits); depth++ {
+			hits += arc.curve.hits[depth]
+		}
+		points[i].Entries = entries
+		if lookups > 0 {
+			points[i].HitRatio = float64(hits) / float64(lookups)
+		}
+	}
+	return points
+}
+
+// resetCurve starts a new hit curve for the ghosts there are, counting lookups from now.
+// Their order across B1 and B2 is not known, so B1's are stamped first,
+// each list from its oldest ghost.
+func (arc *ARC) resetCurve() {
+	curve := newHitCurve(arc.limit)
+	for _, ghosts := range []*LRU{arc.b1List, arc.b2List} {
+		keys := ghosts.Keys()
+		for i := len(keys) - 1; i >= 0; i-- {
+			curve.add(keys[i])
+		}
+	}
+	curve.baseHits, curve.baseMisses = arc.stats.Hits, arc.stats.Misses
+	arc.curve = curve
+}
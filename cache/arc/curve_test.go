@@ -0,0 +1,79 @@
+package arc
+
+import (
+	"math"
+	"testing"
+)
+
+// Tests that ghost hits count towards the sizes that would have held them
+func TestARC_HitCurveDepth(t *testing.T) {
+	arc := newWorkingSetARC(t)
+	// B1 holds t1-0 to t1-19, t1-19 evicted last
+	arc.Get("t1-19")
+	// Bringing t1-19 back evicted another key into B1, in front of t1-0
+	arc.Get("t1-0")
+	curve := arc.HitCurve(101)
+	if len(curve) != 101 || curve[0].Entries != 100 || curve[100].Entries != 200 {
+		t.Fatalf("bad sizes: %+v ... %+v", curve[0], curve[100])
+	}
+	// The 40 Gets of the working set hit, and its 120 Sets were not lookups
+	lookups := float64(arc.Stats().Hits + arc.Stats().Misses + 2)
+	for i, want := range map[int]float64{0: 40, 1: 41, 19: 41, 20: 42, 100: 42} {
+		if got := curve[i].HitRatio * lookups; math.Abs(got-want) > 1e-9 {
+			t.Fatalf("%d entries: %.2f hits, want %.0f", curve[i].Entries, got, want)
+		}
+	}
+	// The curve starts over when the ARC is purged
+	arc.Purge()
+	if curve := arc.HitCurve(2); curve[1].HitRatio != curve[0].HitRatio {
+		t.Fatalf("ghost hits kept after Purge")
+	}
+}
+
+// Tests that the hit ratio observed starts over with the ghost hits on Resize
+func TestARC_HitCurveResize(t *testing.T) {
+	arc := newWorkingSetARC(t)
+	if err := arc.Resize(100); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if curve := arc.HitCurve(2); curve[0].HitRatio != 0 || curve[1].HitRatio != 0 {
+		t.Fatalf("lookups before Resize counted: %+v", curve)
+	}
+	arc.Get("t2-0")
+	arc.Get("missing")
+	if curve := arc.HitCurve(2); curve[0].HitRatio != 0.5 {
+		t.Fatalf("bad hit ratio after Resize: %+v", curve)
+	}
+}
+
+// Tests that the curve estimates the hit ratio of a larger ARC on a skewed workload
+func TestARC_HitCurveEstimate(t *testing.T) {
+	trace, _ := NewTrace("zipf", 50000, 2000, 1)
+	run := func(limit int) *ARC {
+		arc, err := NewARC(limit, WithDirectory(t.TempDir()))
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		for _, key := range trace {
+			if _, ok := arc.Get(key); !ok {
+				arc.Set(key, []byte("v"))
+			}
+		}
+		return arc
+	}
+	curve := run(500).HitCurve(3)
+	for i := 1; i < len(curve); i++ {
+		if curve[i].HitRatio < curve[i-1].HitRatio {
+			t.Fatalf("curve decreases: %+v", curve)
+		}
+	}
+	for _, point := range curve[1:] {
+		larger := run(point.Entries)
+		lookups := larger.Stats().Hits + larger.Stats().Misses + larger.curve.total
+		actual := float64(larger.Stats().Hits) / float64(lookups)
+		t.Logf("%d entries: estimated %.3f, actual %.3f", point.Entries, point.HitRatio, actual)
+		if math.Abs(point.HitRatio-actual) > 0.02 {
+			t.Fatalf("%d entries: estimated %.3f, actual %.3f", point.Entries, point.HitRatio, actual)
+		}
+	}
+}
@@ -0,0 +1,368 @@
+package arc
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Every file written to the on-disk cache directory starts with a header:
+//
+//	magic (3 bytes) | version (1 byte) | codec ID (1 byte) | flags (1 byte)
+//
+// Files without the magic prefix were written before headers existed
+// and hold the raw value. If flagEncrypted is set, the header is followed by
+// the ID of the sealing key, a nonce and the AES-GCM ciphertext of the
+// (possibly compressed) value, authenticated together with the header and key.
+var headerMagic = []byte("ARC")
+
+const (
+	headerVersion = 1
+	headerLen     = 6
+
+	flagEncrypted byte = 1 << 0
+)
+
+// errQuotaExceeded is returned when a value does not fit in the disk quota.
+var errQuotaExceeded = errors.New("disk quota exceeded")
+
+// A QuotaPolicy decides what happens when a value does not fit in the disk quota.
+type QuotaPolicy int
+
+const (
+	// QuotaSkip leaves the value off disk, so its key cannot be
+	// recovered once it is evicted into a ghost list.
+	QuotaSkip QuotaPolicy = iota
+	// QuotaEvictGhosts evicts ghosts early, least recently used first,
+	// until the value fits. The value is skipped if it still does not fit.
+	QuotaEvictGhosts
+)
+
+// DiskStats reports how values have been stored in the on-disk cache directory.
+type DiskStats struct {
+	// Writes is the number of values written.
+	Writes int
+	// CompressedWrites is the number of values stored compressed.
+	CompressedWrites int
+	// RawBytes is the total size of the values written, before compression.
+	RawBytes int64
+	// StoredBytes is the total size of the values as stored, excluding headers.
+	// For encrypted values this includes the key ID, nonce and tag.
+	StoredBytes int64
+	// Rejected is the number of files that could not be read back
+	// because they failed authentication or could not be decoded.
+	Rejected int
+	// Skipped is the number of values that were not persisted,
+	// because they did not fit in the quota or could not be written.
+	Skipped int
+	// QuotaEvictions is the number of ghosts evicted early to make room.
+	QuotaEvictions int
+	// UsedBytes is the current size of the files written by this store.
+	UsedBytes int64
+	// QuotaBytes is the configured quota, or 0 if disk usage is unbounded.
+	QuotaBytes int64
+}
+
+// A diskStore keeps one file per key in a directory,
+// compressing values according to its configuration.
+type diskStore struct {
+	dir             string
+	codec           Codec
+	minCompressSize int
+	// keys encrypts values, or is nil if they are stored in the clear.
+	keys     *keyring
+	fileMode os.FileMode
+	// quota bounds the total size of sizes, unless it is 0.
+	quota       int64
+	quotaPolicy QuotaPolicy
+	// sizes holds the size of each file written by this store.
+	sizes map[string]int64
+	stats DiskStats
+}
+
+// newDiskStore returns a diskStore for dir, creating the directory if needed.
+func newDiskStore(dir string, conf *config) (*diskStore, error) {
+	var store diskStore
+	store.dir = dir
+	if conf.codec != nil {
+		// Files written with an unregistered codec could not be read back.
+		if _, err := lookupCodec(conf.codec.ID()); err != nil {
+			return nil, err
+		}
+	}
+	store.codec = conf.codec
+	store.minCompressSize = conf.minCompressSize
+	store.fileMode = conf.fileMode
+	store.quota = conf.diskQuota
+	store.quotaPolicy = conf.quotaPolicy
+	store.sizes = make(map[string]int64)
+	store.stats.QuotaBytes = conf.diskQuota
+	if conf.encrypt {
+		keys, err := newKeyring(conf.keyID, conf.keys)
+		if err != nil {
+			return nil, err
+		}
+		store.keys = keys
+	}
+	if err := os.MkdirAll(dir, conf.dirMode); err != nil {
+		return nil, err
+	}
+	// MkdirAll leaves an existing directory as it is, so tighten its mode
+	// to clear any permission that dirMode does not grant.
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if perm := info.Mode().Perm(); perm&^conf.dirMode != 0 {
+		if err := os.Chmod(dir, perm&conf.dirMode); err != nil {
+			return nil, err
+		}
+	}
+	return &store, nil
+}
+
+// path returns the name of the file holding key's value.
+func (store *diskStore) path(key string) string {
+	return filepath.Join(store.dir, fileName(key))
+}
+
+// maxFileNameLen bounds file names well below common filesystem limits.
+const maxFileNameLen = 200
+
+// fileName returns a file name for key that stays inside the directory:
+// bytes other than letters, digits, '-' and '_' are escaped as %XX,
+// and names that would be too long are replaced by a hash of the key.
+func fileName(key string) string {
+	var name strings.Builder
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-' || c == '_' {
+			name.WriteByte(c)
+		} else {
+			fmt.Fprintf(&name, "%%%02X", c)
+		}
+	}
+	if name.Len() == 0 || name.Len() > maxFileNameLen {
+		sum := sha256.Sum256([]byte(key))
+		return "%" + hex.EncodeToString(sum[:])
+	}
+	return name.String()
+}
+
+// write stores value under key, replacing any previous value.
+// While the encoded value does not fit in the quota, write calls makeRoom,
+// which reports whether it freed anything; makeRoom may be nil.
+// If the value is not written, any previous value is removed so that
+// it cannot be read back in its place.
+func (store *diskStore) write(key string, value []byte, makeRoom func() bool) error {
+	data, err := store.encode(key, value)
+	for err == nil && !store.fits(key, len(data)) {
+		if makeRoom == nil || !makeRoom() {
+			err = errQuotaExceeded
+		}
+	}
+	if err == nil {
+		err = os.WriteFile(store.path(key), data, store.fileMode)
+	}
+	return store.written(key, value, data, err)
+}
+
+// written records the outcome of writing data, the encoding of value, under key.
+// If err is not nil, the value was not written and any previous value is removed.
+func (store *diskStore) written(key string, value, data []byte, err error) error {
+	if err != nil {
+		store.remove(key)
+		store.stats.Skipped++
+		return err
+	}
+	store.stats.Writes++
+	if data[4] != rawCodecID {
+		store.stats.CompressedWrites++
+	}
+	store.stats.RawBytes += int64(len(value))
+	store.stats.StoredBytes += int64(len(data) - headerLen)
+	store.stats.UsedBytes += int64(len(data)) - store.sizes[key]
+	store.sizes[key] = int64(len(data))
+	return nil
+}
+
+// fits reports whether replacing key's file with one of size bytes
+// keeps the store within its quota.
+func (store *diskStore) fits(key string, size int) bool {
+	if store.quota == 0 {
+		return true
+	}
+	return store.stats.UsedBytes-store.sizes[key]+int64(size) <= store.quota
+}
+
+// encode returns the contents of the file storing value under key.
+func (store *diskStore) encode(key string, value []byte) ([]byte, error) {
+	codecID := rawCodecID
+	payload := value
+	if store.codec != nil && len(value) >= store.minCompressSize {
+		compressed, err := store.codec.Compress(value)
+		// Keep the raw value if compression fails or does not pay off.
+		if err == nil && len(compressed) < len(value) {
+			codecID = store.codec.ID()
+			payload = compressed
+		}
+	}
+
+	var flags byte
+	if store.keys != nil {
+		flags |= flagEncrypted
+	}
+	data := make([]byte, 0, headerLen+len(payload))
+	data = append(data, headerMagic...)
+	data = append(data, headerVersion, codecID, flags)
+	if store.keys != nil {
+		var err error
+		data, err = store.keys.seal(data, payload, additionalData(data, key))
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		data = append(data, payload...)
+	}
+	return data, nil
+}
+
+// writeMany is write for values[i] under keys[i], for a store without a quota.
+// The values are encoded one by one, as codecs need not be safe for concurrent
+// use, and the files are then written in parallel.
+func (store *diskStore) writeMany(keys []string, values [][]byte) {
+	data := make([][]byte, len(keys))
+	errs := make([]error, len(keys))
+	for i, key := range keys {
+		data[i], errs[i] = store.encode(key, values[i])
+	}
+	inParallel(len(keys), func(i int) {
+		if errs[i] == nil {
+			errs[i] = os.WriteFile(store.path(keys[i]), data[i], store.fileMode)
+		}
+	})
+	for i, key := range keys {
+		store.written(key, values[i], data[i], errs[i])
+	}
+}
+
+// read returns the value stored under key.
+func (store *diskStore) read(key string) ([]byte, error) {
+	data, err := os.ReadFile(store.path(key))
+	if err != nil {
+		return nil, err
+	}
+	return store.decoded(key, data)
+}
+
+// decoded returns the value held in data, the contents of key's file,
+// counting the file as rejected if it cannot be decoded.
+func (store *diskStore) decoded(key string, data []byte) ([]byte, error) {
+	value, err := store.decode(key, data)
+	if err != nil {
+		store.stats.Rejected++
+		return nil, err
+	}
+	return value, nil
+}
+
+// readMany is read for many keys at once: the files are read in parallel,
+// then decoded one by one. It returns the values that could be read back.
+func (store *diskStore) readMany(keys []string) map[string][]byte {
+	data := make([][]byte, len(keys))
+	errs := make([]error, len(keys))
+	inParallel(len(keys), func(i int) {
+		data[i], errs[i] = os.ReadFile(store.path(keys[i]))
+	})
+	values := make(map[string][]byte, len(keys))
+	for i, key := range keys {
+		if errs[i] != nil {
+			continue
+		}
+		if value, err := store.decoded(key, data[i]); err == nil {
+			values[key] = value
+		}
+	}
+	return values
+}
+
+// diskParallelism bounds the number of files a batch reads or writes at once.
+const diskParallelism = 16
+
+// inParallel calls f for every index below n, from at most diskParallelism goroutines.
+func inParallel(n int, f func(i int)) {
+	var wg sync.WaitGroup
+	next := int64(-1)
+	for w := 0; w < min(n, diskParallelism); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := int(atomic.AddInt64(&next, 1)); i < n; i = int(atomic.AddInt64(&next, 1)) {
+				f(i)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// decode returns the value held in data, the contents of key's file.
+func (store *diskStore) decode(key string, data []byte) ([]byte, error) {
+	if len(data) < headerLen || !bytes.HasPrefix(data, headerMagic) {
+		// An encrypted store only trusts files it has sealed itself.
+		if store.keys != nil {
+			return nil, errTampered
+		}
+		return data, nil
+	}
+	header := data[:headerLen]
+	codecID := header[4]
+	flags := header[5]
+	payload := data[headerLen:]
+	if store.keys != nil || flags&flagEncrypted != 0 {
+		if store.keys == nil {
+			return nil, errors.New("cache file is encrypted but no key is configured")
+		}
+		if flags&flagEncrypted == 0 {
+			return nil, errTampered
+		}
+		var err error
+		payload, err = store.keys.open(payload, additionalData(header, key))
+		if err != nil {
+			return nil, err
+		}
+	}
+	if codecID == rawCodecID {
+		return payload, nil
+	}
+	codec, err := lookupCodec(codecID)
+	if err != nil {
+		return nil, err
+	}
+	return codec.Decompress(payload)
+}
+
+// additionalData returns the data authenticated alongside an encrypted value,
+// binding the ciphertext to its header and to the key it is stored under.
+func additionalData(header []byte, key string) []byte {
+	additional := make([]byte, 0, headerLen+len(key))
+	additional = append(additional, header[:headerLen]...)
+	return append(additional, key...)
+}
+
+// remove deletes the file holding key's value, if there is one.
+func (store *diskStore) remove(key string) error {
+	store.stats.UsedBytes -= store.sizes[key]
+	delete(store.sizes, key)
+	err := os.Remove(store.path(key))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
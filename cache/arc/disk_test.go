@@ -0,0 +1,220 @@
+package arc
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Tests that a value evicted into B1 is recovered from disk, compressed or not
+func TestARC_DiskGhostRecovery(t *testing.T) {
+	codecs := []Codec{nil, NewFlateCodec(flate.BestSpeed), NewGzipCodec(gzip.BestCompression)}
+	for _, codec := range codecs {
+		l, err := NewARC(2, WithCompression(codec, 16))
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+
+		big := bytes.Repeat([]byte("compressible "), 64)
+		l.Set("small", []byte("tiny"))
+		l.Get("small")
+		l.Set("big", big)
+		// Evicts "big" into B1
+		l.Set("other", []byte("other"))
+		if _, found := l.b1List.Check("big"); !found {
+			t.Fatalf("big should be a ghost in B1")
+		}
+
+		// A ghost hit moves the value back into T2
+		l.Get("big")
+		value, ok := l.Get("big")
+		if !ok || !bytes.Equal(value, big) {
+			t.Fatalf("bad recovered value with codec %T: %q", codec, value)
+		}
+
+		stats := l.DiskStats()
+		if stats.Writes != 3 {
+			t.Fatalf("bad writes: %d", stats.Writes)
+		}
+		if codec == nil && stats.CompressedWrites != 0 {
+			t.Fatalf("bad compressed writes: %d", stats.CompressedWrites)
+		}
+		if codec != nil {
+			if stats.CompressedWrites != 1 {
+				t.Fatalf("bad compressed writes: %d", stats.CompressedWrites)
+			}
+			if stats.StoredBytes >= stats.RawBytes {
+				t.Fatalf("stored %d bytes for %d raw bytes", stats.StoredBytes, stats.RawBytes)
+			}
+		}
+		absolutePath, _ := filepath.Abs("./" + l.cacheDirectory)
+		os.RemoveAll(absolutePath)
+	}
+}
+
+// Tests that files written by different codecs and legacy raw files all read back
+func TestARC_DiskMixedDirectory(t *testing.T) {
+	l, err := NewARC(4, WithCompression(NewGzipCodec(gzip.DefaultCompression), 0))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	flateStore, err := newDiskStore(l.cacheDirectory, newConfig([]Option{WithCompression(NewFlateCodec(flate.DefaultCompression), 0)}))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	value := bytes.Repeat([]byte("mixed "), 32)
+	for i := 0; i < 3; i++ {
+		l.t1List.Set(fmt.Sprintf("%v", i), value)
+	}
+	l.WriteToDisk("0", value)
+	flateStore.write("1", value, nil)
+	os.WriteFile(filepath.Join(l.cacheDirectory, "2"), value, 0666)
+
+	for i := 0; i < 3; i++ {
+		s := fmt.Sprintf("%v", i)
+		if got, ok := l.ReadFromDisk(s); !ok || !bytes.Equal(got, value) {
+			t.Fatalf("bad value for %s: %q", s, got)
+		}
+	}
+	absolutePath, _ := filepath.Abs("./" + l.cacheDirectory)
+	os.RemoveAll(absolutePath)
+}
+
+type identityCodec struct{}
+
+func (identityCodec) ID() byte { return 200 }
+
+func (identityCodec) Compress(src []byte) ([]byte, error) { return src, nil }
+
+func (identityCodec) Decompress(src []byte) ([]byte, error) { return src, nil }
+
+// Tests registration of custom codecs
+func TestRegisterCodec(t *testing.T) {
+	if err := RegisterCodec(NewFlateCodec(flate.BestSpeed)); err == nil {
+		t.Fatalf("reserved codec ID accepted")
+	}
+	if err := RegisterCodec(identityCodec{}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := RegisterCodec(identityCodec{}); err == nil {
+		t.Fatalf("duplicate codec ID accepted")
+	}
+	if codec, err := lookupCodec(200); err != nil || codec != (identityCodec{}) {
+		t.Fatalf("bad lookup: %v %v", codec, err)
+	}
+	codecsMu.Lock()
+	delete(codecs, 200)
+	codecsMu.Unlock()
+}
+
+// Tests that a cache cannot be created with a codec it could not read back
+func TestWithCompression_Unregistered(t *testing.T) {
+	if _, err := NewARC(2, WithDirectory(t.TempDir()), WithCompression(identityCodec{}, 0)); err == nil {
+		t.Fatalf("unregistered codec accepted")
+	}
+	if err := RegisterCodec(identityCodec{}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer func() {
+		codecsMu.Lock()
+		delete(codecs, 200)
+		codecsMu.Unlock()
+	}()
+	if _, err := NewARC(2, WithDirectory(t.TempDir()), WithCompression(identityCodec{}, 0)); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+// Tests that values over the quota are skipped and their ghosts are not recoverable
+func TestARC_DiskQuotaSkip(t *testing.T) {
+	l, err := NewARC(2, WithDiskQuota(64, QuotaSkip))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Set("small", []byte("tiny"))
+	l.Get("small")
+	l.Set("huge", make([]byte, 1024))
+	if _, ok := l.CheckCache("huge"); !ok {
+		t.Fatalf("huge should still be cached in memory")
+	}
+	stats := l.DiskStats()
+	if stats.Skipped != 1 {
+		t.Fatalf("bad skipped: %d", stats.Skipped)
+	}
+	if stats.UsedBytes > stats.QuotaBytes {
+		t.Fatalf("used %d bytes over a quota of %d", stats.UsedBytes, stats.QuotaBytes)
+	}
+
+	// Evicts "huge" into B1, where it cannot be recovered
+	l.Set("other", []byte("other"))
+	if _, found := l.b1List.Check("huge"); !found {
+		t.Fatalf("huge should be a ghost in B1")
+	}
+	l.Get("huge")
+	if _, ok := l.Get("huge"); ok {
+		t.Fatalf("skipped value should not be recoverable")
+	}
+
+	used := l.DiskStats().UsedBytes
+	l.Remove("small")
+	if l.DiskStats().UsedBytes >= used {
+		t.Fatalf("remove did not release disk usage")
+	}
+	absolutePath, _ := filepath.Abs("./" + l.cacheDirectory)
+	os.RemoveAll(absolutePath)
+}
+
+// Tests that ghosts are evicted early to keep disk usage within the quota
+func TestARC_DiskQuotaEvictGhosts(t *testing.T) {
+	value := make([]byte, 100)
+	l, err := NewARC(8, WithDiskQuota(5*int64(headerLen+len(value)), QuotaEvictGhosts))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 16; i++ {
+		s := fmt.Sprintf("%v", i)
+		l.Set(s, value)
+		// Move to t2, so that later insertions evict into b2
+		l.Get(s)
+		stats := l.DiskStats()
+		if stats.UsedBytes > stats.QuotaBytes {
+			t.Fatalf("used %d bytes over a quota of %d", stats.UsedBytes, stats.QuotaBytes)
+		}
+	}
+	stats := l.DiskStats()
+	if stats.QuotaEvictions == 0 {
+		t.Fatalf("no ghosts evicted early")
+	}
+	if stats.Skipped == 0 {
+		t.Fatalf("values should be skipped while there are no ghosts")
+	}
+	absolutePath, _ := filepath.Abs("./" + l.cacheDirectory)
+	os.RemoveAll(absolutePath)
+}
+
+// Tests that an existing world-writable cache directory is made owner-only
+func TestDiskStore_TightensExistingDirectory(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "cache_directory")
+	if err := os.Mkdir(dir, 0777); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	// Mkdir is subject to the umask, so set the legacy mode explicitly
+	if err := os.Chmod(dir, 0777); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := NewARC(2, WithDirectory(dir)); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if mode := info.Mode().Perm(); mode != 0700 {
+		t.Fatalf("directory mode left at %v", mode)
+	}
+}
@@ -0,0 +1,219 @@
+package arc
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// groupReplicas is the number of points each peer has on a Group's ring.
+const groupReplicas = 64
+
+// groupTimeout bounds a fetch from a peer.
+const groupTimeout = 5 * time.Second
+
+// A Group is one peer of a cache shared by several processes.
+// A consistent hash ring assigns each key an owner among the peers.
+// The owner caches the key in its local ARC, loading it from the origin on a miss;
+// other peers fetch it from the owner over HTTP and keep it in a small hot ARC
+// so that popular keys aren't fetched on every use.
+//
+// Every peer serves the keys it owns through ServeHTTP under /keys/,
+// and names itself and its peers by the base URL they serve on.
+type Group struct {
+	self   string
+	local  *SyncARC
+	hot    *SyncARC
+	serve  *HTTPHandler
+	client *http.Client
+
+	mu   sync.RWMutex
+	ring *HashRing
+
+	localGets, peerGets, hotHits, peerErrors, peerServes int64
+}
+
+// GroupStats counts where a Group found the values it was asked for.
+type GroupStats struct {
+	// Gets of keys this peer owns.
+	LocalGets int64
+	// Gets of keys owned by another peer, and how many of them were hot.
+	PeerGets int64
+	HotHits  int64
+	// Fetches from a peer that failed, so the key was loaded locally instead.
+	PeerErrors int64
+	// Requests from other peers for keys this peer owns.
+	PeerServes int64
+}
+
+// NewGroup returns a peer named self, whose local ARC holds up to limit entries
+// and whose hot ARC holds up to hotLimit entries of keys owned by other peers.
+// The options apply to both ARCs, except that the hot ARC has no origin and is
+// stored in a directory of its own beside the local one.
+// The group starts with self as its only peer.
+func NewGroup(self string, limit int, hotLimit int, opts ...Option) (*Group, error) {
+	self = strings.TrimSuffix(self, "/")
+	local, err := NewSyncARC(limit, opts...)
+	if err != nil {
+		return nil, err
+	}
+	hotOpts := append(opts[:len(opts):len(opts)], WithDirectory(newConfig(opts).dir+"_hot"), withoutOrigin())
+	hot, err := NewSyncARC(hotLimit, hotOpts...)
+	if err != nil {
+		local.Close()
+		return nil, err
+	}
+	var group Group
+	group.self = self
+	group.local = local
+	group.hot = hot
+	group.serve = NewHTTPHandler(local)
+	group.client = &http.Client{Timeout: groupTimeout}
+	group.ring = NewHashRing(groupReplicas)
+	group.ring.Add(self)
+	return &group, nil
+}
+
+// withoutOrigin undoes WithOrigin.
+func withoutOrigin() Option {
+	return func(conf *config) {
+		conf.origin = nil
+		conf.flushInterval = 0
+	}
+}
+
+// Close flushes and closes the group's ARCs.
+func (group *Group) Close() error {
+	err := group.local.Close()
+	if hotErr := group.hot.Close(); err == nil {
+		err = hotErr
+	}
+	return err
+}
+
+// SetPeers replaces the group's peers. self is always one of them.
+// Keys that change owner are fetched from their new owner from then on,
+// and the hot ARC is purged since the keys it holds may now be owned here.
+func (group *Group) SetPeers(peers ...string) {
+	ring := NewHashRing(groupReplicas)
+	ring.Add(group.self)
+	for _, peer := range peers {
+		ring.Add(strings.TrimSuffix(peer, "/"))
+	}
+	group.mu.Lock()
+	group.ring = ring
+	group.mu.Unlock()
+	group.hot.Purge()
+}
+
+// AddPeer adds a peer to the group.
+func (group *Group) AddPeer(peer string) {
+	group.SetPeers(append(group.Peers(), peer)...)
+}
+
+// RemovePeer removes a peer from the group. self cannot be removed.
+func (group *Group) RemovePeer(peer string) {
+	peer = strings.TrimSuffix(peer, "/")
+	var peers []string
+	for _, other := range group.Peers() {
+		if other != peer {
+			peers = append(peers, other)
+		}
+	}
+	group.SetPeers(peers...)
+}
+
+// Peers returns the group's peers, including self, in sorted order.
+func (group *Group) Peers() []string {
+	group.mu.RLock()
+	defer group.mu.RUnlock()
+	return group.ring.Peers()
+}
+
+// Owner returns the peer that owns key.
+func (group *Group) Owner(key string) string {
+	group.mu.RLock()
+	defer group.mu.RUnlock()
+	return group.ring.Get(key)
+}
+
+// Get returns the value of key, from the local ARC if this peer owns it
+// and from its owner otherwise.
+// If the owner cannot be reached, the value is loaded locally instead.
+func (group *Group) Get(key string) (value []byte, ok bool) {
+	owner := group.Owner(key)
+	if owner == group.self {
+		atomic.AddInt64(&group.localGets, 1)
+		return group.local.Get(key)
+	}
+	atomic.AddInt64(&group.peerGets, 1)
+	if value, ok := group.hot.Get(key); ok {
+		atomic.AddInt64(&group.hotHits, 1)
+		return value, true
+	}
+	value, ok, err := group.fetch(owner, key)
+	if err != nil {
+		atomic.AddInt64(&group.peerErrors, 1)
+		return group.local.Get(key)
+	}
+	if ok {
+		group.hot.Set(key, value)
+	}
+	return value, ok
+}
+
+// fetch gets key from the peer that owns it.
+func (group *Group) fetch(owner string, key string) (value []byte, ok bool, err error) {
+	response, err := group.client.Get(owner + "/keys/" + url.PathEscape(key))
+	if err != nil {
+		return nil, false, err
+	}
+	defer response.Body.Close()
+	switch response.StatusCode {
+	case http.StatusOK:
+		value, err = io.ReadAll(response.Body)
+		if err != nil {
+			return nil, false, err
+		}
+		return value, true, nil
+	case http.StatusNotFound:
+		return nil, false, nil
+	}
+	return nil, false, fmt.Errorf("peer %s: %s", owner, response.Status)
+}
+
+// ServeHTTP serves the keys this peer owns to the other peers.
+func (group *Group) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet || !strings.HasPrefix(r.URL.Path, "/keys/") {
+		http.NotFound(w, r)
+		return
+	}
+	atomic.AddInt64(&group.peerServes, 1)
+	group.serve.ServeHTTP(w, r)
+}
+
+// Stats returns a snapshot of the group's statistics.
+func (group *Group) Stats() *GroupStats {
+	var stats GroupStats
+	stats.LocalGets = atomic.LoadInt64(&group.localGets)
+	stats.PeerGets = atomic.LoadInt64(&group.peerGets)
+	stats.HotHits = atomic.LoadInt64(&group.hotHits)
+	stats.PeerErrors = atomic.LoadInt64(&group.peerErrors)
+	stats.PeerServes = atomic.LoadInt64(&group.peerServes)
+	return &stats
+}
+
+// Local returns the ARC holding the keys this peer owns.
+func (group *Group) Local() *SyncARC {
+	return group.local
+}
+
+// Hot returns the ARC holding keys fetched from other peers.
+func (group *Group) Hot() *SyncARC {
+	return group.hot
+}
@@ -0,0 +1,174 @@
+package arc
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"path/filepath"
+	"testing"
+)
+
+// startGroup starts n peers over loopback, all in front of origin.
+func startGroup(t *testing.T, n int, origin Origin) ([]*Group, []*http.Server) {
+	var groups []*Group
+	var servers []*http.Server
+	var peers []string
+	for i := 0; i < n; i++ {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		self := "http://" + listener.Addr().String()
+		dir := filepath.Join(t.TempDir(), "peer")
+		group, err := NewGroup(self, 64, 32, WithDirectory(dir), WithOrigin(origin, WriteThrough))
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		server := &http.Server{Handler: group}
+		go server.Serve(listener)
+		t.Cleanup(func() {
+			server.Close()
+			group.Close()
+		})
+		groups = append(groups, group)
+		servers = append(servers, server)
+		peers = append(peers, self)
+	}
+	for _, group := range groups {
+		group.SetPeers(peers...)
+	}
+	return groups, servers
+}
+
+// originLoads sums the origin loads of the groups' local ARCs.
+func originLoads(groups []*Group) int {
+	loads := 0
+	for _, group := range groups {
+		loads += group.Local().OriginStats().Loads
+	}
+	return loads
+}
+
+// Tests that the ring spreads keys evenly and moves few of them when a peer joins
+func TestHashRing(t *testing.T) {
+	ring := NewHashRing(64)
+	if owner := ring.Get("a"); owner != "" {
+		t.Fatalf("empty ring has owner %q", owner)
+	}
+	ring.Add("a", "b", "c")
+	counts := make(map[string]int)
+	owners := make(map[string]string)
+	for i := 0; i < 3000; i++ {
+		key := fmt.Sprint(i)
+		owners[key] = ring.Get(key)
+		counts[owners[key]]++
+	}
+	for _, peer := range ring.Peers() {
+		if counts[peer] < 500 {
+			t.Fatalf("uneven spread: %v", counts)
+		}
+	}
+
+	ring.Add("d")
+	moved := 0
+	for key, owner := range owners {
+		if now := ring.Get(key); now != owner {
+			if now != "d" {
+				t.Fatalf("%s moved from %s to %s", key, owner, now)
+			}
+			moved++
+		}
+	}
+	if moved == 0 || moved > 1500 {
+		t.Fatalf("moved %d keys", moved)
+	}
+	ring.Remove("d")
+	for key, owner := range owners {
+		if now := ring.Get(key); now != owner {
+			t.Fatalf("%s did not move back to %s", key, owner)
+		}
+	}
+}
+
+// Tests that each key is loaded from the origin once across the group
+func TestGroup_Get(t *testing.T) {
+	origin := newMapOrigin()
+	for i := 0; i < 20; i++ {
+		origin.values[fmt.Sprint(i)] = []byte(fmt.Sprint("value", i))
+	}
+	groups, _ := startGroup(t, 3, origin)
+
+	for round := 0; round < 2; round++ {
+		for _, group := range groups {
+			for i := 0; i < 20; i++ {
+				value, ok := group.Get(fmt.Sprint(i))
+				if !ok || string(value) != fmt.Sprint("value", i) {
+					t.Fatalf("bad value for %d: %q %v", i, value, ok)
+				}
+			}
+			if _, ok := group.Get("missing"); ok {
+				t.Fatalf("missing key found")
+			}
+		}
+	}
+	if loads := originLoads(groups); loads != 20 {
+		t.Fatalf("loaded %d values from the origin, want 20", loads)
+	}
+	for _, group := range groups {
+		stats := group.Stats()
+		if stats.PeerGets == 0 || stats.HotHits == 0 || stats.PeerServes == 0 || stats.PeerErrors != 0 {
+			t.Fatalf("bad stats: %+v", stats)
+		}
+		if stats.LocalGets+stats.PeerGets != 42 {
+			t.Fatalf("bad get count: %+v", stats)
+		}
+	}
+}
+
+// Tests changing membership at runtime, and falling back when a peer is down
+func TestGroup_Membership(t *testing.T) {
+	origin := newMapOrigin()
+	for i := 0; i < 20; i++ {
+		origin.values[fmt.Sprint(i)] = []byte(fmt.Sprint(i))
+	}
+	groups, servers := startGroup(t, 3, origin)
+
+	// Take the last peer down without telling the others
+	servers[2].Close()
+	down := groups[2].Peers()
+	for i := 0; i < 20; i++ {
+		if value, ok := groups[0].Get(fmt.Sprint(i)); !ok || string(value) != fmt.Sprint(i) {
+			t.Fatalf("bad value for %d: %q %v", i, value, ok)
+		}
+	}
+	if groups[0].Stats().PeerErrors == 0 {
+		t.Fatalf("no fetches failed")
+	}
+
+	for _, group := range groups[:2] {
+		group.RemovePeer(groups[2].self)
+		if len(group.Peers()) != 2 {
+			t.Fatalf("bad peers: %v", group.Peers())
+		}
+	}
+	for i := 0; i < 20; i++ {
+		key := fmt.Sprint(i)
+		if owner := groups[0].Owner(key); owner == groups[2].self {
+			t.Fatalf("%s still owned by the removed peer", key)
+		}
+	}
+	errors := groups[0].Stats().PeerErrors
+	for i := 0; i < 20; i++ {
+		if _, ok := groups[1].Get(fmt.Sprint(i)); !ok {
+			t.Fatalf("lost key %d", i)
+		}
+	}
+	if groups[0].Stats().PeerErrors != errors || groups[1].Stats().PeerErrors != 0 {
+		t.Fatalf("fetch failed after the peer was removed")
+	}
+
+	groups[0].SetPeers(down...)
+	if len(groups[0].Peers()) != 3 {
+		t.Fatalf("bad peers: %v", groups[0].Peers())
+	}
+}
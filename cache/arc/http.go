@@ -0,0 +1,242 @@
+package arc
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// maxHTTPValue bounds the size of a value PUT through an HTTPHandler.
+const maxHTTPValue = 64 << 20
+
+// An HTTPHandler exposes a SyncARC as a REST service:
+//
+//	GET    /keys/{key}  returns the value, counting a use
+//	HEAD   /keys/{key}  reports whether the key is cached, without counting a use
+//	PUT    /keys/{key}  sets the value to the request body
+//	DELETE /keys/{key}  removes the key
+//	GET    /stats       returns the cache's statistics as JSON
+//
+// Values carry a strong ETag, and requests may be made conditional
+// with If-Match and If-None-Match.
+type HTTPHandler struct {
+	cache *SyncARC
+}
+
+// NewHTTPHandler returns an HTTPHandler serving cache.
+func NewHTTPHandler(cache *SyncARC) *HTTPHandler {
+	var handler HTTPHandler
+	handler.cache = cache
+	return &handler
+}
+
+// ServeHTTP implements http.Handler.
+func (handler *HTTPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/stats":
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		handler.serveStats(w, r)
+	case strings.HasPrefix(r.URL.Path, "/keys/") && len(r.URL.Path) > len("/keys/"):
+		handler.serveKey(w, r, strings.TrimPrefix(r.URL.Path, "/keys/"))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// serveKey handles requests for /keys/{key}.
+func (handler *HTTPHandler) serveKey(w http.ResponseWriter, r *http.Request, key string) {
+	switch r.Method {
+	case http.MethodGet:
+		value, ok := handler.cache.Get(key)
+		handler.writeValue(w, r, value, ok, true)
+	case http.MethodHead:
+		value, ok := handler.cache.CheckCache(key)
+		handler.writeValue(w, r, value, ok, false)
+	case http.MethodPut:
+		handler.put(w, r, key)
+	case http.MethodDelete:
+		handler.delete(w, r, key)
+	default:
+		w.Header().Set("Allow", "GET, HEAD, PUT, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// writeValue answers a GET or HEAD for a value, honouring If-None-Match.
+func (handler *HTTPHandler) writeValue(w http.ResponseWriter, r *http.Request, value []byte, ok bool, body bool) {
+	if !ok {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	tag := etag(value)
+	w.Header().Set("ETag", tag)
+	if etagMatches(r.Header.Get("If-None-Match"), tag, true) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.Itoa(len(value)))
+	w.WriteHeader(http.StatusOK)
+	if body {
+		w.Write(value)
+	}
+}
+
+// put sets the value of key to the request body.
+// It answers 201 if the key was created and 204 if it was replaced,
+// or 202 if the admission filter turned the key away; the value then
+// only reaches the origin, if there is one.
+func (handler *HTTPHandler) put(w http.ResponseWriter, r *http.Request, key string) {
+	value, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxHTTPValue))
+	if err != nil {
+		http.Error(w, "value too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	status := http.StatusNoContent
+	handler.cache.Do(func(arc *ARC) {
+		arc.dropIfExpired(key)
+		old, exists := arc.CheckCache(key)
+		if !preconditionsHold(r, old, exists) {
+			status = http.StatusPreconditionFailed
+			return
+		}
+		if !exists {
+			status = http.StatusCreated
+		}
+		switch err := arc.TrySet(key, value, 0); {
+		case err == ErrNotAdmitted:
+			status = http.StatusAccepted
+		case err != nil:
+			status = http.StatusBadGateway
+		}
+	})
+	if status == http.StatusPreconditionFailed || status == http.StatusBadGateway {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	if status != http.StatusAccepted {
+		w.Header().Set("ETag", etag(value))
+	}
+	w.WriteHeader(status)
+}
+
+// delete removes key, answering 204 if it was cached and 404 otherwise.
+func (handler *HTTPHandler) delete(w http.ResponseWriter, r *http.Request, key string) {
+	status := http.StatusNoContent
+	handler.cache.Do(func(arc *ARC) {
+		arc.dropIfExpired(key)
+		old, exists := arc.CheckCache(key)
+		if !preconditionsHold(r, old, exists) {
+			status = http.StatusPreconditionFailed
+			return
+		}
+		// Remove also drops a ghost, but a ghost is not cached, as GET and HEAD answer.
+		arc.Remove(key)
+		if !exists {
+			status = http.StatusNotFound
+		}
+	})
+	if status != http.StatusNoContent {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	w.WriteHeader(status)
+}
+
+// httpStats is the JSON body of a /stats response.
+type httpStats struct {
+	Hits         int            `json:"hits"`
+	Misses       int            `json:"misses"`
+	Len          int            `json:"len"`
+	Limit        int            `json:"limit"`
+	T1           int            `json:"t1"`
+	T2           int            `json:"t2"`
+	B1           int            `json:"b1"`
+	B2           int            `json:"b2"`
+	TargetMarker int            `json:"target_marker"`
+	Disk         DiskStats      `json:"disk"`
+	Admission    AdmissionStats `json:"admission"`
+}
+
+// serveStats handles GET /stats.
+func (handler *HTTPHandler) serveStats(w http.ResponseWriter, r *http.Request) {
+	var stats httpStats
+	handler.cache.Do(func(arc *ARC) {
+		stats.Hits = arc.Stats().Hits
+		stats.Misses = arc.Stats().Misses
+		stats.Len = arc.Len()
+		stats.Limit = arc.MaxEntries()
+		stats.T1, stats.T2, stats.B1, stats.B2 = arc.ListLens()
+		stats.TargetMarker = arc.TargetMarker()
+		stats.Disk = *arc.DiskStats()
+		stats.Admission = *arc.AdmissionStats()
+	})
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+// preconditionsHold evaluates the If-Match and If-None-Match headers of a write
+// against the current value of its key.
+func preconditionsHold(r *http.Request, value []byte, exists bool) bool {
+	if match := r.Header.Get("If-Match"); match != "" {
+		if !exists || !etagMatches(match, etag(value), false) {
+			return false
+		}
+	}
+	if noneMatch := r.Header.Get("If-None-Match"); noneMatch != "" {
+		if exists && etagMatches(noneMatch, etag(value), true) {
+			return false
+		}
+	}
+	return true
+}
+
+// etag returns the strong entity tag of value.
+func etag(value []byte) string {
+	sum := sha256.Sum256(value)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// etagMatches reports whether the If-Match or If-None-Match header
+// value header lists tag, or is "*". Weak tags only match if weak is set:
+// If-None-Match uses weak comparison, and If-Match strong comparison (RFC 9110 §13.1).
+func etagMatches(header string, tag string, weak bool) bool {
+	for header != "" {
+		var candidate string
+		candidate, header = nextETag(header)
+		if candidate == "*" || candidate == tag || (weak && candidate == "W/"+tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// nextETag splits the first entity tag off a comma-separated list.
+func nextETag(list string) (tag string, rest string) {
+	for len(list) > 0 && (list[0] == ' ' || list[0] == ',') {
+		list = list[1:]
+	}
+	end := 0
+	quoted := false
+	for end < len(list) {
+		if list[end] == '"' {
+			quoted = !quoted
+		} else if list[end] == ',' && !quoted {
+			break
+		}
+		end++
+	}
+	tag = list[:end]
+	for len(tag) > 0 && tag[len(tag)-1] == ' ' {
+		tag = tag[:len(tag)-1]
+	}
+	return tag, list[end:]
+}
@@ -0,0 +1,172 @@
+package arc
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// startHTTPServer serves a new SyncARC over loopback and returns its URL.
+func startHTTPServer(t *testing.T, limit int, opts ...Option) string {
+	cache, err := NewSyncARC(limit, append([]Option{WithDirectory(t.TempDir())}, opts...)...)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	server := httptest.NewServer(NewHTTPHandler(cache))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+// httpDo sends a request and returns the response with its body read.
+func httpDo(t *testing.T, method string, url string, body string, header ...string) (*http.Response, string) {
+	request, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		request.Header.Set(header[i], header[i+1])
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer response.Body.Close()
+	data, err := io.ReadAll(response.Body)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	return response, string(data)
+}
+
+func expectStatus(t *testing.T, response *http.Response, want int) {
+	t.Helper()
+	if response.StatusCode != want {
+		t.Fatalf("%s %s: got %d, want %d", response.Request.Method, response.Request.URL.Path, response.StatusCode, want)
+	}
+}
+
+// Tests GET, HEAD, PUT and DELETE on keys
+func TestHTTP_Keys(t *testing.T) {
+	url := startHTTPServer(t, 16)
+
+	response, _ := httpDo(t, "GET", url+"/keys/a", "")
+	expectStatus(t, response, http.StatusNotFound)
+	response, _ = httpDo(t, "PUT", url+"/keys/a", "hello")
+	expectStatus(t, response, http.StatusCreated)
+	response, _ = httpDo(t, "PUT", url+"/keys/a", "world")
+	expectStatus(t, response, http.StatusNoContent)
+	response, body := httpDo(t, "GET", url+"/keys/a", "")
+	expectStatus(t, response, http.StatusOK)
+	if body != "world" {
+		t.Fatalf("bad body: %q", body)
+	}
+
+	response, _ = httpDo(t, "PUT", url+"/keys/dir/with%20space", "x")
+	expectStatus(t, response, http.StatusCreated)
+	response, body = httpDo(t, "GET", url+"/keys/dir/with%20space", "")
+	if response.StatusCode != http.StatusOK || body != "x" {
+		t.Fatalf("bad nested key: %d %q", response.StatusCode, body)
+	}
+
+	response, _ = httpDo(t, "DELETE", url+"/keys/a", "")
+	expectStatus(t, response, http.StatusNoContent)
+	response, _ = httpDo(t, "DELETE", url+"/keys/a", "")
+	expectStatus(t, response, http.StatusNotFound)
+	response, _ = httpDo(t, "POST", url+"/keys/a", "")
+	expectStatus(t, response, http.StatusMethodNotAllowed)
+}
+
+// Tests that DELETE answers 404 for a ghost, as GET and HEAD do
+func TestHTTP_DeleteGhost(t *testing.T) {
+	url := startHTTPServer(t, 2)
+	httpDo(t, "PUT", url+"/keys/a", "1")
+	httpDo(t, "GET", url+"/keys/a", "")
+	httpDo(t, "PUT", url+"/keys/b", "2")
+	// Evicts b from T1 into B1
+	httpDo(t, "PUT", url+"/keys/c", "3")
+	response, _ := httpDo(t, "HEAD", url+"/keys/b", "")
+	expectStatus(t, response, http.StatusNotFound)
+	response, _ = httpDo(t, "DELETE", url+"/keys/b", "")
+	expectStatus(t, response, http.StatusNotFound)
+}
+
+// Tests that a key turned away by the admission filter is accepted, not failed
+func TestHTTP_NotAdmitted(t *testing.T) {
+	url := startHTTPServer(t, 16, WithAdmissionFilter(0.001))
+	response, _ := httpDo(t, "PUT", url+"/keys/a", "1")
+	expectStatus(t, response, http.StatusAccepted)
+	if response.Header.Get("ETag") != "" {
+		t.Fatalf("ETag sent for a value not stored")
+	}
+	response, _ = httpDo(t, "GET", url+"/keys/a", "")
+	expectStatus(t, response, http.StatusNotFound)
+	response, _ = httpDo(t, "PUT", url+"/keys/a", "1")
+	expectStatus(t, response, http.StatusCreated)
+}
+
+// Tests that HEAD does not count a use, so the key stays in T1
+func TestHTTP_HeadDoesNotPromote(t *testing.T) {
+	url := startHTTPServer(t, 16)
+
+	httpDo(t, "PUT", url+"/keys/a", "hello")
+	response, body := httpDo(t, "HEAD", url+"/keys/a", "")
+	expectStatus(t, response, http.StatusOK)
+	if body != "" || response.ContentLength != 5 || response.Header.Get("ETag") == "" {
+		t.Fatalf("bad head reply: %q %d %q", body, response.ContentLength, response.Header.Get("ETag"))
+	}
+	response, _ = httpDo(t, "HEAD", url+"/keys/missing", "")
+	expectStatus(t, response, http.StatusNotFound)
+
+	var stats httpStats
+	_, body = httpDo(t, "GET", url+"/stats", "")
+	if err := json.Unmarshal([]byte(body), &stats); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if stats.T1 != 1 || stats.T2 != 0 || stats.Hits != 0 || stats.Limit != 16 {
+		t.Fatalf("bad stats: %+v", stats)
+	}
+}
+
+// Tests conditional requests with ETags
+func TestHTTP_Conditional(t *testing.T) {
+	url := startHTTPServer(t, 16)
+
+	response, _ := httpDo(t, "PUT", url+"/keys/a", "v1")
+	tag := response.Header.Get("ETag")
+	if tag == "" {
+		t.Fatalf("put returned no etag")
+	}
+
+	response, _ = httpDo(t, "GET", url+"/keys/a", "", "If-None-Match", tag)
+	expectStatus(t, response, http.StatusNotModified)
+	response, _ = httpDo(t, "GET", url+"/keys/a", "", "If-None-Match", `"stale", `+tag)
+	expectStatus(t, response, http.StatusNotModified)
+
+	response, _ = httpDo(t, "PUT", url+"/keys/a", "new", "If-None-Match", "*")
+	expectStatus(t, response, http.StatusPreconditionFailed)
+	response, _ = httpDo(t, "PUT", url+"/keys/b", "new", "If-None-Match", "*")
+	expectStatus(t, response, http.StatusCreated)
+
+	response, _ = httpDo(t, "PUT", url+"/keys/a", "v2", "If-Match", `"stale"`)
+	expectStatus(t, response, http.StatusPreconditionFailed)
+	// If-Match compares strongly, so a weak tag fails, while If-None-Match accepts it
+	response, _ = httpDo(t, "PUT", url+"/keys/a", "v2", "If-Match", "W/"+tag)
+	expectStatus(t, response, http.StatusPreconditionFailed)
+	response, _ = httpDo(t, "GET", url+"/keys/a", "", "If-None-Match", "W/"+tag)
+	expectStatus(t, response, http.StatusNotModified)
+	response, _ = httpDo(t, "PUT", url+"/keys/a", "v2", "If-Match", tag)
+	expectStatus(t, response, http.StatusNoContent)
+	if response.Header.Get("ETag") == tag {
+		t.Fatalf("etag did not change")
+	}
+
+	response, _ = httpDo(t, "DELETE", url+"/keys/a", "", "If-Match", tag)
+	expectStatus(t, response, http.StatusPreconditionFailed)
+	response, body := httpDo(t, "GET", url+"/keys/a", "")
+	if response.StatusCode != http.StatusOK || body != "v2" {
+		t.Fatalf("bad value after failed delete: %d %q", response.StatusCode, body)
+	}
+}
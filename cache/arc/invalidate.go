@@ -0,0 +1,274 @@
+package arc
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// An Invalidation announces that the value of Key changed at Version.
+// Source identifies the Invalidator that made the change.
+type Invalidation struct {
+	Key     string
+	Version uint64
+	Source  string
+}
+
+// A Bus carries invalidations between the processes sharing an origin.
+type Bus interface {
+	// Publish sends inv to every subscriber, possibly including the sender's own.
+	Publish(msg Invalidation) error
+	// Subscribe calls fn for every invalidation received until unsubscribe is called.
+	// fn may be called from several goroutines at once.
+	Subscribe(fn func(msg Invalidation)) (unsubscribe func())
+	// Close stops the bus.
+	Close() error
+}
+
+// subscribers is the subscription list embedded in each Bus.
+type subscribers struct {
+	mu    sync.Mutex
+	next  int
+	funcs map[int]func(Invalidation)
+}
+
+// Subscribe implements Bus.
+func (subs *subscribers) Subscribe(fn func(msg Invalidation)) (unsubscribe func()) {
+	subs.mu.Lock()
+	defer subs.mu.Unlock()
+	if subs.funcs == nil {
+		subs.funcs = make(map[int]func(Invalidation))
+	}
+	id := subs.next
+	subs.next++
+	subs.funcs[id] = fn
+	return func() {
+		subs.mu.Lock()
+		defer subs.mu.Unlock()
+		delete(subs.funcs, id)
+	}
+}
+
+// deliver calls every subscriber with msg.
+func (subs *subscribers) deliver(msg Invalidation) {
+	subs.mu.Lock()
+	funcs := make([]func(Invalidation), 0, len(subs.funcs))
+	for _, fn := range subs.funcs {
+		funcs = append(funcs, fn)
+	}
+	subs.mu.Unlock()
+	for _, fn := range funcs {
+		fn(msg)
+	}
+}
+
+// A LocalBus delivers invalidations between Invalidators in the same process.
+type LocalBus struct {
+	subscribers
+}
+
+// NewLocalBus returns an in-process Bus.
+func NewLocalBus() *LocalBus {
+	return &LocalBus{}
+}
+
+// Publish implements Bus. Subscribers are called before it returns.
+func (bus *LocalBus) Publish(msg Invalidation) error {
+	bus.deliver(msg)
+	return nil
+}
+
+// Close implements Bus.
+func (bus *LocalBus) Close() error {
+	return nil
+}
+
+// InvalidationStats counts the invalidations an Invalidator sent and received.
+type InvalidationStats struct {
+	Published int64
+	Received  int64
+	// Received invalidations that dropped a binding,
+	// and those ignored because the binding was newer.
+	Applied int64
+	Stale   int64
+}
+
+// An Invalidator keeps a SyncARC coherent with the other caches in front of
+// the same origin. Changes made through it are announced on a Bus, and
+// announcements from the other caches drop the changed keys from this one,
+// so the next use reads the new value through from the origin.
+//
+// Each change is stamped with a version from a hybrid logical clock. An
+// invalidation older than the version of the binding this cache holds was
+// overtaken by a later change, and is ignored rather than dropping the newer value.
+//
+// Invalidations drop bindings without calling ARC.Remove,
+// which would delete the new value from the shared origin too.
+// In WriteBack mode they also discard the dirty value of the binding without
+// storing it in the origin: the change announced is newer, and storing the
+// older value would overwrite it.
+type Invalidator struct {
+	cache       *SyncARC
+	bus         Bus
+	id          string
+	unsubscribe func()
+
+	mu sync.Mutex
+	// The latest version issued or seen.
+	clock uint64
+	// The version of each binding changed through or invalidated by the Invalidator.
+	versions map[string]uint64
+
+	published, received, applied, stale int64
+}
+
+// NewInvalidator returns an Invalidator for cache, subscribed to bus.
+func NewInvalidator(cache *SyncARC, bus Bus) *Invalidator {
+	var id [8]byte
+	rand.Read(id[:])
+	var inv Invalidator
+	inv.cache = cache
+	inv.bus = bus
+	inv.id = hex.EncodeToString(id[:])
+	inv.versions = make(map[string]uint64)
+	inv.unsubscribe = bus.Subscribe(inv.receive)
+	return &inv
+}
+
+// Close unsubscribes the Invalidator from its bus.
+// It closes neither the bus nor the cache.
+func (inv *Invalidator) Close() {
+	inv.unsubscribe()
+}
+
+// Set sets the value of key in the cache and announces the change.
+// It returns false, announcing nothing, if the cache could not store value.
+func (inv *Invalidator) Set(key string, value []byte) (bool, error) {
+	inv.mu.Lock()
+	version := inv.tick()
+	ok := inv.cache.Set(key, value)
+	if ok {
+		inv.versions[key] = version
+		inv.prune()
+	}
+	inv.mu.Unlock()
+	if !ok {
+		return false, nil
+	}
+	return true, inv.publish(key, version)
+}
+
+// Remove removes key from the cache and its origin, and announces the change.
+func (inv *Invalidator) Remove(key string) (value []byte, ok bool, err error) {
+	inv.mu.Lock()
+	version := inv.tick()
+	value, ok = inv.cache.Remove(key)
+	inv.versions[key] = version
+	inv.prune()
+	inv.mu.Unlock()
+	return value, ok, inv.publish(key, version)
+}
+
+// Invalidate drops key from the cache and announces a change to it,
+// for use when the origin was changed by other means.
+func (inv *Invalidator) Invalidate(key string) error {
+	inv.mu.Lock()
+	version := inv.tick()
+	inv.drop(key)
+	inv.versions[key] = version
+	inv.prune()
+	inv.mu.Unlock()
+	return inv.publish(key, version)
+}
+
+// Stats returns a snapshot of the Invalidator's statistics.
+func (inv *Invalidator) Stats() *InvalidationStats {
+	var stats InvalidationStats
+	stats.Published = atomic.LoadInt64(&inv.published)
+	stats.Received = atomic.LoadInt64(&inv.received)
+	stats.Applied = atomic.LoadInt64(&inv.applied)
+	stats.Stale = atomic.LoadInt64(&inv.stale)
+	return &stats
+}
+
+// publish announces a change to key at version.
+func (inv *Invalidator) publish(key string, version uint64) error {
+	atomic.AddInt64(&inv.published, 1)
+	return inv.bus.Publish(Invalidation{Key: key, Version: version, Source: inv.id})
+}
+
+// receive applies an invalidation from the bus.
+func (inv *Invalidator) receive(msg Invalidation) {
+	if msg.Source == inv.id {
+		return
+	}
+	atomic.AddInt64(&inv.received, 1)
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	if msg.Version > inv.clock {
+		inv.clock = msg.Version
+	}
+	if inv.versions[msg.Key] >= msg.Version {
+		atomic.AddInt64(&inv.stale, 1)
+		return
+	}
+	inv.versions[msg.Key] = msg.Version
+	inv.prune()
+	inv.drop(msg.Key)
+	atomic.AddInt64(&inv.applied, 1)
+}
+
+// drop drops key from the cache, discarding any unflushed value,
+// which the change being announced supersedes.
+func (inv *Invalidator) drop(key string) {
+	inv.cache.Do(func(arc *ARC) {
+		delete(arc.dirty, key)
+		arc.drop(key)
+	})
+}
+
+// tick returns a new version, later than every version issued or seen so far.
+// Versions follow the wall clock where they can, so they order changes made by
+// different processes, and fall back to counting when the wall clock lags.
+func (inv *Invalidator) tick() uint64 {
+	now := uint64(time.Now().UnixNano())
+	if now <= inv.clock {
+		now = inv.clock + 1
+	}
+	inv.clock = now
+	return now
+}
+
+// prune forgets versions once there are more than twice as many as the cache has
+// entries, cutting back to as many as it has entries so that the cost is spread
+// over the changes that follow. The versions of keys the cache no longer holds go
+// first, as invalidations of them have nothing to drop, then the oldest versions.
+// An invalidation of a key whose version was forgotten is applied, at worst
+// dropping a newer value, which is then read through again.
+func (inv *Invalidator) prune() {
+	limit := inv.cache.MaxEntries()
+	if len(inv.versions) <= 2*limit {
+		return
+	}
+	inv.cache.Do(func(arc *ARC) {
+		for key := range inv.versions {
+			if _, ok := arc.CheckCacheDirectory(key); !ok {
+				delete(inv.versions, key)
+			}
+		}
+	})
+	if len(inv.versions) <= limit {
+		return
+	}
+	keys := make([]string, 0, len(inv.versions))
+	for key := range inv.versions {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return inv.versions[keys[i]] < inv.versions[keys[j]] })
+	for _, key := range keys[:len(keys)-limit] {
+		delete(inv.versions, key)
+	}
+}
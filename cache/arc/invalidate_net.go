@@ -0,0 +1,235 @@
+package arc
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// maxInvalidation bounds the encoded size of an invalidation,
+// so that one always fits in a UDP datagram.
+const maxInvalidation = 60 << 10
+
+// busDialTimeout bounds connecting to a TCPBus peer.
+const busDialTimeout = time.Second
+
+var errInvalidationTooLarge = errors.New("arc: invalidation too large")
+
+// encodeInvalidation encodes msg as its version, the length of its source,
+// its source and its key.
+func encodeInvalidation(msg Invalidation) ([]byte, error) {
+	if len(msg.Source) > 255 || 9+len(msg.Source)+len(msg.Key) > maxInvalidation {
+		return nil, errInvalidationTooLarge
+	}
+	data := make([]byte, 9, 9+len(msg.Source)+len(msg.Key))
+	binary.BigEndian.PutUint64(data, msg.Version)
+	data[8] = byte(len(msg.Source))
+	data = append(data, msg.Source...)
+	data = append(data, msg.Key...)
+	return data, nil
+}
+
+// decodeInvalidation decodes an invalidation encoded by encodeInvalidation.
+func decodeInvalidation(data []byte) (msg Invalidation, ok bool) {
+	if len(data) < 9 || len(data) < 9+int(data[8]) {
+		return msg, false
+	}
+	msg.Version = binary.BigEndian.Uint64(data)
+	msg.Source = string(data[9 : 9+int(data[8])])
+	msg.Key = string(data[9+int(data[8]):])
+	return msg, true
+}
+
+// A TCPBus fans invalidations out over TCP to a list of peers,
+// and delivers those that peers send to it to its subscribers.
+// Each peer is sent invalidations in the order they were published.
+type TCPBus struct {
+	connServer
+	subscribers
+
+	mu    sync.Mutex
+	peers []string
+	// Connections to peers, dialled on first use.
+	out map[string]net.Conn
+}
+
+// NewTCPBus returns a bus that publishes to peers, given as TCP addresses.
+// Invalidations from peers are only received once Serve is called.
+func NewTCPBus(peers ...string) *TCPBus {
+	var bus TCPBus
+	bus.connServer.init(bus.handleConn)
+	bus.peers = peers
+	bus.out = make(map[string]net.Conn)
+	return &bus
+}
+
+// SetPeers replaces the peers the bus publishes to.
+func (bus *TCPBus) SetPeers(peers ...string) {
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+	keep := make(map[string]bool)
+	for _, peer := range peers {
+		keep[peer] = true
+	}
+	for peer, conn := range bus.out {
+		if !keep[peer] {
+			conn.Close()
+			delete(bus.out, peer)
+		}
+	}
+	bus.peers = peers
+}
+
+// Publish implements Bus, sending msg to every peer.
+// It returns the first error met, after trying every peer.
+func (bus *TCPBus) Publish(msg Invalidation) error {
+	data, err := encodeInvalidation(msg)
+	if err != nil {
+		return err
+	}
+	frame := make([]byte, 4, 4+len(data))
+	binary.BigEndian.PutUint32(frame, uint32(len(data)))
+	frame = append(frame, data...)
+
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+	var first error
+	for _, peer := range bus.peers {
+		if err := bus.send(peer, frame); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// send writes frame to peer, redialling once if the connection has broken.
+func (bus *TCPBus) send(peer string, frame []byte) error {
+	for attempt := 0; ; attempt++ {
+		conn, ok := bus.out[peer]
+		if !ok {
+			var err error
+			conn, err = net.DialTimeout("tcp", peer, busDialTimeout)
+			if err != nil {
+				return err
+			}
+			bus.out[peer] = conn
+		}
+		conn.SetWriteDeadline(time.Now().Add(busDialTimeout))
+		_, err := conn.Write(frame)
+		if err == nil {
+			return nil
+		}
+		conn.Close()
+		delete(bus.out, peer)
+		if attempt > 0 {
+			return err
+		}
+	}
+}
+
+// handleConn delivers the invalidations a peer sends on conn.
+func (bus *TCPBus) handleConn(conn net.Conn) {
+	reader := bufio.NewReader(conn)
+	var size [4]byte
+	for {
+		if _, err := io.ReadFull(reader, size[:]); err != nil {
+			return
+		}
+		n := binary.BigEndian.Uint32(size[:])
+		if n > maxInvalidation {
+			return
+		}
+		data := make([]byte, n)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return
+		}
+		if msg, ok := decodeInvalidation(data); ok {
+			bus.deliver(msg)
+		}
+	}
+}
+
+// Close implements Bus, closing the bus's listeners and connections.
+func (bus *TCPBus) Close() error {
+	bus.mu.Lock()
+	for peer, conn := range bus.out {
+		conn.Close()
+		delete(bus.out, peer)
+	}
+	bus.peers = nil
+	bus.mu.Unlock()
+	return bus.connServer.Close()
+}
+
+// A MulticastBus publishes invalidations as UDP datagrams to a multicast group,
+// and delivers those sent to the group to its subscribers.
+// Delivery is unreliable and unordered, as UDP is.
+type MulticastBus struct {
+	subscribers
+
+	send *net.UDPConn
+	recv *net.UDPConn
+	wg   sync.WaitGroup
+}
+
+// NewMulticastBus joins the multicast group at address, such as "239.0.0.1:7946",
+// on the network interface ifi, or the system's choice of interface if ifi is nil.
+func NewMulticastBus(address string, ifi *net.Interface) (*MulticastBus, error) {
+	group, err := net.ResolveUDPAddr("udp", address)
+	if err != nil {
+		return nil, err
+	}
+	recv, err := net.ListenMulticastUDP("udp", ifi, group)
+	if err != nil {
+		return nil, err
+	}
+	send, err := net.DialUDP("udp", nil, group)
+	if err != nil {
+		recv.Close()
+		return nil, err
+	}
+	var bus MulticastBus
+	bus.send = send
+	bus.recv = recv
+	bus.wg.Add(1)
+	go bus.receive()
+	return &bus, nil
+}
+
+// Publish implements Bus, sending msg to the group.
+// The bus's own subscribers receive it too.
+func (bus *MulticastBus) Publish(msg Invalidation) error {
+	data, err := encodeInvalidation(msg)
+	if err != nil {
+		return err
+	}
+	_, err = bus.send.Write(data)
+	return err
+}
+
+// receive delivers datagrams from the group until the bus is closed.
+func (bus *MulticastBus) receive() {
+	defer bus.wg.Done()
+	buffer := make([]byte, maxInvalidation)
+	for {
+		n, _, err := bus.recv.ReadFromUDP(buffer)
+		if err != nil {
+			return
+		}
+		if msg, ok := decodeInvalidation(buffer[:n]); ok {
+			bus.deliver(msg)
+		}
+	}
+}
+
+// Close implements Bus, leaving the group.
+func (bus *MulticastBus) Close() error {
+	err := bus.recv.Close()
+	bus.send.Close()
+	bus.wg.Wait()
+	return err
+}
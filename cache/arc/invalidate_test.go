@@ -0,0 +1,242 @@
+package arc
+
+import (
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// newInvalidatedCache returns a SyncARC in front of origin with an Invalidator on bus.
+func newInvalidatedCache(t *testing.T, origin Origin, bus Bus) (*SyncARC, *Invalidator) {
+	cache, err := NewSyncARC(16, WithDirectory(t.TempDir()), WithOrigin(origin, WriteThrough))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	inv := NewInvalidator(cache, bus)
+	t.Cleanup(func() {
+		inv.Close()
+		cache.Close()
+	})
+	return cache, inv
+}
+
+// waitFor polls cond until it holds or a second has passed.
+func waitFor(cond func() bool) bool {
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if cond() {
+			return true
+		}
+	}
+	return cond()
+}
+
+// Tests that a change through one Invalidator drops stale copies from the others
+func TestInvalidator_LocalBus(t *testing.T) {
+	origin := newMapOrigin()
+	bus := NewLocalBus()
+	a, invA := newInvalidatedCache(t, origin, bus)
+	b, invB := newInvalidatedCache(t, origin, bus)
+
+	if ok, err := invA.Set("k", []byte("v1")); !ok || err != nil {
+		t.Fatalf("set failed: %v", err)
+	}
+	if value, ok := b.Get("k"); !ok || string(value) != "v1" {
+		t.Fatalf("bad value: %q", value)
+	}
+	invA.Set("k", []byte("v2"))
+	if _, ok := b.CheckCache("k"); ok {
+		t.Fatalf("stale copy not dropped")
+	}
+	if value, ok := b.Get("k"); !ok || string(value) != "v2" {
+		t.Fatalf("bad value after invalidation: %q", value)
+	}
+	if value, ok := a.Get("k"); !ok || string(value) != "v2" {
+		t.Fatalf("writer lost its own value: %q", value)
+	}
+
+	invB.Remove("k")
+	if _, ok := a.CheckCache("k"); ok || origin.has("k") {
+		t.Fatalf("remove not propagated")
+	}
+	stats := invA.Stats()
+	if stats.Published != 2 || stats.Received != 1 || stats.Applied != 1 {
+		t.Fatalf("bad stats: %+v", stats)
+	}
+}
+
+// Tests that a late invalidation doesn't drop a newer value
+func TestInvalidator_Stale(t *testing.T) {
+	origin := newMapOrigin()
+	bus := NewLocalBus()
+	cache, inv := newInvalidatedCache(t, origin, bus)
+
+	late := Invalidation{Key: "k", Version: uint64(time.Now().UnixNano()), Source: "other"}
+	inv.Set("k", []byte("new"))
+	bus.Publish(late)
+	if value, ok := cache.CheckCache("k"); !ok || string(value) != "new" {
+		t.Fatalf("late invalidation dropped the newer value")
+	}
+	if stats := inv.Stats(); stats.Stale != 1 || stats.Applied != 0 {
+		t.Fatalf("bad stats: %+v", stats)
+	}
+
+	// An invalidation seen from another process moves the clock past it,
+	// so the next local change is newer still
+	future := Invalidation{Key: "k", Version: uint64(time.Now().Add(time.Hour).UnixNano()), Source: "other"}
+	bus.Publish(future)
+	if _, ok := cache.CheckCache("k"); ok {
+		t.Fatalf("newer invalidation not applied")
+	}
+	inv.Set("k", []byte("newer"))
+	bus.Publish(future)
+	if _, ok := cache.CheckCache("k"); !ok {
+		t.Fatalf("replayed invalidation dropped a later value")
+	}
+}
+
+// Tests that an invalidation discards a dirty write-back value the change overtook,
+// rather than storing it over the newer value in the origin
+func TestInvalidator_WriteBack(t *testing.T) {
+	origin := newMapOrigin()
+	bus := NewLocalBus()
+	writer, invWriter := newInvalidatedCache(t, origin, bus)
+	cache, err := NewSyncARC(16, WithDirectory(t.TempDir()), WithOrigin(origin, WriteBack))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	inv := NewInvalidator(cache, bus)
+	t.Cleanup(func() {
+		inv.Close()
+		cache.Close()
+	})
+
+	inv.Set("k", []byte("old"))
+	invWriter.Set("k", []byte("new"))
+	if _, ok := cache.CheckCache("k"); ok || cache.OriginStats().Dirty != 0 {
+		t.Fatalf("dirty value not discarded")
+	}
+	if err := cache.Flush(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if value, ok := cache.Get("k"); !ok || string(value) != "new" {
+		t.Fatalf("bad value after invalidation: %q", value)
+	}
+	if value, _ := writer.CheckCache("k"); string(value) != "new" {
+		t.Fatalf("writer lost its value: %q", value)
+	}
+}
+
+// Tests that versions are pruned back to the cache's capacity, keeping the newest
+func TestInvalidator_Prune(t *testing.T) {
+	_, inv := newInvalidatedCache(t, newMapOrigin(), NewLocalBus())
+
+	for i := 0; i < 32; i++ {
+		inv.Set("k"+strconv.Itoa(i), []byte("v"))
+	}
+	if n := len(inv.versions); n != 32 {
+		t.Fatalf("pruned too early: %d versions", n)
+	}
+	inv.Set("k32", []byte("v"))
+	if n := len(inv.versions); n != 16 {
+		t.Fatalf("bad versions after prune: %d", n)
+	}
+	for i := 17; i <= 32; i++ {
+		if _, ok := inv.versions["k"+strconv.Itoa(i)]; !ok {
+			t.Fatalf("newest version of k%d forgotten", i)
+		}
+	}
+}
+
+// Tests invalidation over TCP fan-out on loopback
+func TestInvalidator_TCPBus(t *testing.T) {
+	origin := newMapOrigin()
+	var buses []*TCPBus
+	var addrs []string
+	for i := 0; i < 3; i++ {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		bus := NewTCPBus()
+		go bus.Serve(listener)
+		t.Cleanup(func() { bus.Close() })
+		buses = append(buses, bus)
+		addrs = append(addrs, listener.Addr().String())
+	}
+	var caches []*SyncARC
+	var invs []*Invalidator
+	for i, bus := range buses {
+		var peers []string
+		for j, addr := range addrs {
+			if j != i {
+				peers = append(peers, addr)
+			}
+		}
+		bus.SetPeers(peers...)
+		cache, inv := newInvalidatedCache(t, origin, bus)
+		caches = append(caches, cache)
+		invs = append(invs, inv)
+	}
+
+	invs[0].Set("k", []byte("v1"))
+	for _, cache := range caches[1:] {
+		cache.Get("k")
+	}
+	if _, err := invs[0].Set("k", []byte("v2")); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i, cache := range caches[1:] {
+		if !waitFor(func() bool { _, ok := cache.CheckCache("k"); return !ok }) {
+			t.Fatalf("peer %d kept a stale copy", i+1)
+		}
+		if value, _ := cache.Get("k"); string(value) != "v2" {
+			t.Fatalf("bad value: %q", value)
+		}
+	}
+
+	// A peer that goes away doesn't stop the others hearing of changes
+	buses[2].Close()
+	caches[1].Get("k")
+	invs[0].Set("k", []byte("v3"))
+	if !waitFor(func() bool { _, ok := caches[1].CheckCache("k"); return !ok }) {
+		t.Fatalf("live peer kept a stale copy")
+	}
+}
+
+// Tests invalidation over UDP multicast, where the host supports it
+func TestInvalidator_MulticastBus(t *testing.T) {
+	probe, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	port := probe.LocalAddr().(*net.UDPAddr).Port
+	probe.Close()
+	address := net.JoinHostPort("239.255.42.99", strconv.Itoa(port))
+
+	origin := newMapOrigin()
+	var caches []*SyncARC
+	var invs []*Invalidator
+	for i := 0; i < 2; i++ {
+		bus, err := NewMulticastBus(address, nil)
+		if err != nil {
+			t.Skipf("multicast unavailable: %v", err)
+		}
+		t.Cleanup(func() { bus.Close() })
+		cache, inv := newInvalidatedCache(t, origin, bus)
+		caches = append(caches, cache)
+		invs = append(invs, inv)
+	}
+
+	invs[0].Set("k", []byte("v1"))
+	caches[1].Get("k")
+	if _, err := invs[0].Set("k", []byte("v2")); err != nil {
+		t.Skipf("multicast unavailable: %v", err)
+	}
+	if !waitFor(func() bool { return invs[1].Stats().Received > 0 }) {
+		t.Skipf("multicast datagrams not delivered on this host")
+	}
+	if _, ok := caches[1].CheckCache("k"); ok {
+		t.Fatalf("stale copy not dropped")
+	}
+}
@@ -0,0 +1,48 @@
+//go:build go1.23
+
+package arc
+
+import "iter"
+
+// All returns an iterator over the bindings in the cache, in T1 and then T2,
+// each from the most to the least recently used. No binding counts as used,
+// and the ARC must not be modified during the iteration.
+func (arc *ARC) All() iter.Seq2[string, []byte] {
+	return func(yield func(string, []byte) bool) {
+		arc.Range(func(key string, value []byte, loc Location) bool {
+			if loc != T1 && loc != T2 {
+				return false
+			}
+			return yield(key, value)
+		})
+	}
+}
+
+// List returns an iterator over the keys in loc, from the most to the least
+// recently used, with their values. The values of ghosts, in B1 or B2, are nil.
+// No key counts as used, and the ARC must not be modified during the iteration.
+func (arc *ARC) List(loc Location) iter.Seq2[string, []byte] {
+	lru := arc.list(loc)
+	if lru == nil {
+		return func(yield func(string, []byte) bool) {}
+	}
+	return lru.All()
+}
+
+// All returns an iterator over the bindings in the LRU, from the most to the least
+// recently used. No binding counts as used, and the LRU must not be modified
+// during the iteration.
+func (lru *LRU) All() iter.Seq2[string, []byte] {
+	return lru.Range
+}
+
+// All returns an iterator over the bindings in the cache.
+// The lock is held until the iteration stops, so the loop must not use the SyncARC.
+// See ARC.All.
+func (sarc *SyncARC) All() iter.Seq2[string, []byte] {
+	return func(yield func(string, []byte) bool) {
+		sarc.mu.Lock()
+		defer sarc.mu.Unlock()
+		sarc.arc.All()(yield)
+	}
+}
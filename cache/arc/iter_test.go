@@ -0,0 +1,58 @@
+//go:build go1.23
+
+package arc
+
+import (
+	"testing"
+)
+
+// Tests the iterators over an ARC's bindings and lists
+func TestARC_Iterators(t *testing.T) {
+	arc := newWorkingSetARC(t)
+	t1, t2, b1, _ := arc.Keys()
+	n := 0
+	for key, value := range arc.All() {
+		if string(value) != "v" {
+			t.Fatalf("%s: bad value %q", key, value)
+		}
+		n++
+	}
+	if n != len(t1)+len(t2) {
+		t.Fatalf("All yielded %d bindings", n)
+	}
+	var ghosts []string
+	for key, value := range arc.List(B1) {
+		if value != nil {
+			t.Fatalf("ghost %s has a value", key)
+		}
+		ghosts = append(ghosts, key)
+		if len(ghosts) == 3 {
+			break
+		}
+	}
+	if len(ghosts) != 3 || ghosts[0] != b1[0] {
+		t.Fatalf("bad ghosts: %v", ghosts)
+	}
+	for range arc.List(Location(0)) {
+		t.Fatalf("unknown list yielded a key")
+	}
+}
+
+// Tests that a SyncARC releases its lock when an iteration stops early
+func TestSyncARC_All(t *testing.T) {
+	sarc, err := NewSyncARC(10, WithDirectory(t.TempDir()))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	sarc.Set("a", []byte("1"))
+	sarc.Set("b", []byte("2"))
+	for key := range sarc.All() {
+		if key != "b" {
+			t.Fatalf("most recent key is %s", key)
+		}
+		break
+	}
+	if !sarc.Contains("a") {
+		t.Fatalf("a not found")
+	}
+}
@@ -0,0 +1,207 @@
+package arc
+
+import (
+	"container/list"
+)
+
+// An LFU is a fixed-size in-memory cache with least-frequently-used eviction,
+// and the same API as LRU. Keys are kept in frequency buckets, so every operation
+// takes constant time, as Shah, Mitra and Matani describe; among the least
+// frequently used keys, the least recently used is evicted first.
+type LFU struct {
+	cache map[string]*lfuEntry
+	// Buckets of keys used equally often, least frequent at the front. Elements hold *lfuBucket.
+	buckets     *list.List
+	usedEntries int
+	limit       int
+	stats       Stats
+}
+
+// An lfuBucket holds the keys used freq times, most recently used at the front.
+type lfuBucket struct {
+	freq    int
+	entries *list.List
+}
+
+type lfuEntry struct {
+	key     string
+	bytes   []byte
+	bucket  *list.Element
+	element *list.Element
+}
+
+// NewLFU returns a pointer to a new LFU with a capacity to store limit entries.
+func NewLFU(limit int) *LFU {
+	var lfu LFU
+	lfu.cache = make(map[string]*lfuEntry)
+	lfu.buckets = list.New()
+	lfu.limit = limit
+	return &lfu
+}
+
+// MaxEntries returns the maximum number of entries this LFU can store
+func (lfu *LFU) MaxEntries() int {
+	return lfu.limit
+}
+
+// RemainingSpaces returns the number of unused spaces for entries available in this LFU
+func (lfu *LFU) RemainingSpaces() int {
+	return lfu.limit - lfu.usedEntries
+}
+
+// Get returns the value associated with the given key, if it exists.
+// This operation counts as a "use" for that key-value pair
+// ok is true if a value was found and false otherwise.
+func (lfu *LFU) Get(key string) (value []byte, ok bool) {
+	entry, found := lfu.cache[key]
+	if !found {
+		lfu.stats.Misses++
+		return nil, false
+	}
+	lfu.stats.Hits++
+	lfu.touch(entry)
+	return entry.bytes, true
+}
+
+// Check returns the value associated with the given key, if it exists.
+// This operation DOES NOT counts as a "use" for that key-value pair
+// ok is true if a value was found and false otherwise.
+func (lfu *LFU) Check(key string) (value []byte, ok bool) {
+	if entry, found := lfu.cache[key]; found {
+		return entry.bytes, true
+	}
+	return nil, false
+}
+
+// Frequency returns the number of uses of key since it was set, counting the Set.
+func (lfu *LFU) Frequency(key string) int {
+	if entry, found := lfu.cache[key]; found {
+		return entry.bucket.Value.(*lfuBucket).freq
+	}
+	return 0
+}
+
+// Remove removes and returns the value associated with the given key, if it exists.
+// ok is true if a value was found and false otherwise
+func (lfu *LFU) Remove(key string) (value []byte, ok bool) {
+	entry, found := lfu.cache[key]
+	if !found {
+		return nil, false
+	}
+	lfu.unlink(entry)
+	return entry.bytes, true
+}
+
+// Evict removes the least frequently used binding from the LFU
+// and returns the key associated with it.
+func (lfu *LFU) Evict() (key string, ok bool) {
+	key, _, ok = lfu.Back()
+	if ok {
+		lfu.unlink(lfu.cache[key])
+	}
+	return key, ok
+}
+
+// Back returns the least frequently used binding, the one Evict would remove,
+// without removing it or counting it as a use.
+func (lfu *LFU) Back() (key string, value []byte, ok bool) {
+	front := lfu.buckets.Front()
+	if front == nil {
+		return "", nil, false
+	}
+	entry := front.Value.(*lfuBucket).entries.Back().Value.(*lfuEntry)
+	return entry.key, entry.bytes, true
+}
+
+// Set associates the given value with the given key, possibly evicting values
+// to make room. Returns true if the binding was added successfully, else false.
+// Replacing the value of a key counts as a use.
+func (lfu *LFU) Set(key string, value []byte) bool {
+	if entry, found := lfu.cache[key]; found {
+		entry.bytes = value
+		lfu.touch(entry)
+		return true
+	}
+	if lfu.limit <= 0 {
+		return false
+	}
+	if lfu.RemainingSpaces() == 0 {
+		lfu.Evict()
+	}
+	entry := &lfuEntry{key: key, bytes: value}
+	entry.bucket = lfu.buckets.Front()
+	if entry.bucket == nil || entry.bucket.Value.(*lfuBucket).freq != 1 {
+		entry.bucket = lfu.buckets.PushFront(&lfuBucket{freq: 1, entries: list.New()})
+	}
+	entry.element = entry.bucket.Value.(*lfuBucket).entries.PushFront(entry)
+	lfu.cache[key] = entry
+	lfu.usedEntries++
+	return true
+}
+
+// touch moves entry to the bucket for one more use, creating it if need be.
+func (lfu *LFU) touch(entry *lfuEntry) {
+	bucket := entry.bucket.Value.(*lfuBucket)
+	next := entry.bucket.Next()
+	if next == nil || next.Value.(*lfuBucket).freq != bucket.freq+1 {
+		next = lfu.buckets.InsertAfter(&lfuBucket{freq: bucket.freq + 1, entries: list.New()}, entry.bucket)
+	}
+	bucket.entries.Remove(entry.element)
+	if bucket.entries.Len() == 0 {
+		lfu.buckets.Remove(entry.bucket)
+	}
+	entry.bucket = next
+	entry.element = next.Value.(*lfuBucket).entries.PushFront(entry)
+}
+
+// unlink removes entry from its bucket and the cache.
+func (lfu *LFU) unlink(entry *lfuEntry) {
+	bucket := entry.bucket.Value.(*lfuBucket)
+	bucket.entries.Remove(entry.element)
+	if bucket.entries.Len() == 0 {
+		lfu.buckets.Remove(entry.bucket)
+	}
+	delete(lfu.cache, entry.key)
+	lfu.usedEntries--
+}
+
+// Len returns the number of bindings in the LFU.
+func (lfu *LFU) Len() int {
+	return lfu.usedEntries
+}
+
+// Stats returns statistics about how many search hits and misses have occurred.
+func (lfu *LFU) Stats() *Stats {
+	return &lfu.stats
+}
+
+// keyCache is the part of the API of LRU, LFU and AgingLFU
+// that a Policy needs to keep its keys in one.
+type keyCache interface {
+	Set(key string, value []byte) bool
+	Evict() (key string, ok bool)
+	Remove(key string) (value []byte, ok bool)
+}
+
+// keyCachePolicy evicts the keys a keyCache, such as an LFU, would evict.
+type keyCachePolicy struct {
+	keys keyCache
+}
+
+func (policy *keyCachePolicy) OnHit(key string) {
+	policy.keys.Set(key, nil)
+}
+
+func (policy *keyCachePolicy) OnMiss(key string) {}
+
+func (policy *keyCachePolicy) OnInsert(key string) {
+	policy.keys.Set(key, nil)
+}
+
+func (policy *keyCachePolicy) Victim() (key string, ok bool) {
+	return policy.keys.Evict()
+}
+
+func (policy *keyCachePolicy) Remove(key string) {
+	policy.keys.Remove(key)
+}
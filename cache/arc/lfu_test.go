@@ -0,0 +1,175 @@
+package arc
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// Computes hit ratio for accessing random entries in an LFU
+func BenchmarkLFU_Rand(b *testing.B) {
+	l := NewLFU(8192)
+
+	trace := make([]int64, b.N*2)
+	for i := 0; i < b.N*2; i++ {
+		trace[i] = rand.Int63() % 32768
+	}
+
+	b.ResetTimer()
+
+	for i := 0; i < 2*b.N; i++ {
+		s := fmt.Sprintf("%v", trace[i])
+		if i%2 == 0 {
+			b := make([]byte, 8)
+			binary.LittleEndian.PutUint64(b, uint64(trace[i]))
+
+			l.Set(s, b)
+		} else {
+			l.Get(s)
+		}
+	}
+	hits := l.stats.Hits
+	misses := l.stats.Misses
+	b.Logf("hit: %d miss: %d ratio: %f", hits, misses, float64(hits)/float64(misses))
+}
+
+// Compute hit ratio for a linear sequence of accesses
+func BenchmarkLFU_Freq(b *testing.B) {
+	l := NewLFU(8192)
+
+	trace := make([]int64, b.N*2)
+	for i := 0; i < b.N*2; i++ {
+		if i%2 == 0 {
+			trace[i] = rand.Int63() % 16384
+		} else {
+			trace[i] = rand.Int63() % 32768
+		}
+	}
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		b := make([]byte, 8)
+		binary.LittleEndian.PutUint64(b, uint64(trace[i]))
+		s := fmt.Sprintf("%v", trace[i])
+
+		l.Set(s, b)
+	}
+	for i := 0; i < b.N; i++ {
+		s := fmt.Sprintf("%v", trace[i])
+		l.Get(s)
+	}
+	hits := l.stats.Hits
+	misses := l.stats.Misses
+	b.Logf("hit: %d miss: %d ratio: %f", hits, misses, float64(hits)/float64(misses))
+}
+
+// Tests that an LFU evicts the least frequently used key, and the least recent among equals
+func TestLFU(t *testing.T) {
+	lfu := NewLFU(3)
+	lfu.Set("a", []byte("1"))
+	lfu.Set("b", []byte("2"))
+	lfu.Set("c", []byte("3"))
+	lfu.Get("a")
+	lfu.Get("a")
+	lfu.Get("b")
+	if lfu.Frequency("a") != 3 || lfu.Frequency("c") != 1 {
+		t.Fatalf("bad frequencies: %d %d", lfu.Frequency("a"), lfu.Frequency("c"))
+	}
+	// c is the least frequently used
+	lfu.Set("d", []byte("4"))
+	if _, ok := lfu.Check("c"); ok {
+		t.Fatalf("c should have been evicted")
+	}
+	// d was used once, like nothing else, so it goes next
+	if key, _, _ := lfu.Back(); key != "d" {
+		t.Fatalf("bad back: %s", key)
+	}
+	lfu.Get("d")
+	// b and d were used twice, and b less recently
+	if key, ok := lfu.Evict(); !ok || key != "b" {
+		t.Fatalf("bad eviction: %s", key)
+	}
+	if value, ok := lfu.Remove("a"); !ok || string(value) != "1" {
+		t.Fatalf("bad remove: %s", value)
+	}
+	if lfu.Len() != 1 || lfu.RemainingSpaces() != 2 || lfu.MaxEntries() != 3 {
+		t.Fatalf("bad size: %d", lfu.Len())
+	}
+	if stats := lfu.Stats(); stats.Hits != 4 || stats.Misses != 0 {
+		t.Fatalf("bad stats: %+v", stats)
+	}
+}
+
+// Tests that LFU-DA ages out keys that were popular once, where LFU keeps them
+func TestLFUDA_Aging(t *testing.T) {
+	lfu, lfuda := NewLFU(10), NewLFUDA(10)
+	for _, cache := range []interface {
+		Get(key string) ([]byte, bool)
+		Set(key string, value []byte) bool
+	}{lfu, lfuda} {
+		// The old keys are used ten times each, then never again
+		for i := 0; i < 10; i++ {
+			for j := 0; j < 10; j++ {
+				cache.Set(fmt.Sprint("old", j), []byte("v"))
+			}
+		}
+		// The new keys are used over and over, twice each time round
+		for i := 0; i < 100; i++ {
+			for j := 0; j < 5; j++ {
+				key := fmt.Sprint("new", j)
+				if _, ok := cache.Get(key); !ok {
+					cache.Set(key, []byte("v"))
+				}
+				cache.Get(key)
+			}
+		}
+	}
+	if _, ok := lfu.Check("new0"); ok {
+		t.Fatalf("LFU should never keep a new key")
+	}
+	for j := 0; j < 5; j++ {
+		if _, ok := lfuda.Check(fmt.Sprint("new", j)); !ok {
+			t.Fatalf("LFU-DA should keep new%d", j)
+		}
+	}
+	if lfuda.Age() == 0 {
+		t.Fatalf("LFU-DA did not age")
+	}
+}
+
+// Tests that GDSF evicts a large value before small ones used as often, where LFU-DA does not
+func TestGDSF_Size(t *testing.T) {
+	gdsf, lfuda := NewGDSF(3), NewLFUDA(3)
+	for _, cache := range []*AgingLFU{gdsf, lfuda} {
+		cache.Set("small1", []byte("s"))
+		cache.Set("small2", []byte("s"))
+		cache.Set("large", make([]byte, 1000))
+		cache.Set("small3", []byte("s"))
+	}
+	if _, ok := gdsf.Check("large"); ok {
+		t.Fatalf("GDSF should have evicted the large value")
+	}
+	if _, ok := lfuda.Check("small1"); ok {
+		t.Fatalf("LFU-DA should have evicted the least recently set value")
+	}
+	if priority, _ := gdsf.Priority("small3"); priority != gdsf.Age()+1 || gdsf.Age() != 0.001 {
+		t.Fatalf("bad priority: %f", priority)
+	}
+	if value, ok := gdsf.Remove("small1"); !ok || string(value) != "s" || gdsf.Len() != 2 {
+		t.Fatalf("bad remove")
+	}
+}
+
+// Tests LFU and LFU-DA as policies against LRU on a Zipf trace
+func TestLFU_HitRatio(t *testing.T) {
+	trace, _ := NewTrace("zipf", 200000, 50000, 1)
+	lru, _ := Simulate("lru", 1000, trace)
+	lfu, _ := Simulate("lfu", 1000, trace)
+	lfuda, _ := Simulate("lfuda", 1000, trace)
+	t.Logf("zipf: lru %.4f lfu %.4f lfuda %.4f", lru.HitRatio(), lfu.HitRatio(), lfuda.HitRatio())
+	if lfu.HitRatio() <= lru.HitRatio() || lfuda.HitRatio() <= lru.HitRatio() {
+		t.Fatalf("frequency should beat recency on a Zipf trace")
+	}
+}
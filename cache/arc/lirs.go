@@ -0,0 +1,207 @@
+package arc
+
+import (
+	"container/list"
+	"errors"
+)
+
+// defaultHIRRatio is the share of a LIRS cache given to resident HIR keys,
+// from Jiang and Zhang's recommendation.
+const defaultHIRRatio = 0.01
+
+// States of a key known to LIRS.
+const (
+	// A LIR key has a low inter-reference recency, and is always resident.
+	lirsLIR = iota
+	// A resident HIR key has a high inter-reference recency, and is evicted first.
+	lirsHIR
+	// A non-resident HIR key has been evicted, but is remembered in S so that a
+	// quick return promotes it straight to LIR.
+	lirsGhost
+)
+
+// lirsPolicy is LIRS, as Jiang and Zhang describe it.
+// Keys are ranked by inter-reference recency, the number of other keys used between
+// their last two uses, rather than recency alone, so a loop slightly larger than the
+// cache keeps most of its keys resident where LRU and ARC keep none of them.
+type lirsPolicy struct {
+	// Stack S, with the most recent key at the front. Its back is always a LIR key.
+	stack *list.List
+	// Queue Q of resident HIR keys, with the next victim at the front.
+	queue *list.List
+	// Non-resident HIR keys in the order they were evicted, oldest at the front.
+	ghosts  *list.List
+	entries map[string]*lirsEntry
+	// Number of LIR keys, and the most there may be.
+	lirs    int
+	maxLIRs int
+	limit   int
+}
+
+// A lirsEntry is a key known to LIRS, with its elements in S, Q and the ghosts.
+type lirsEntry struct {
+	key   string
+	state int
+	s     *list.Element
+	q     *list.Element
+	ghost *list.Element
+}
+
+// NewLIRS returns a cache with room for limit entries managed by LIRS,
+// where hirRatio is the share of the cache given to resident HIR keys.
+// The "lirs" policy uses 0.01.
+func NewLIRS(limit int, hirRatio float64, opts ...Option) (*PolicyCache, error) {
+	if hirRatio <= 0 || hirRatio >= 1 {
+		return nil, errors.New("arc: LIRS needs 0 < hirRatio < 1")
+	}
+	if limit <= 0 {
+		return nil, errors.New("Capacity must be greater than zero")
+	}
+	return newPolicyCache("lirs", newLIRSPolicy(limit, hirRatio), limit, opts)
+}
+
+func newLIRSPolicy(limit int, hirRatio float64) *lirsPolicy {
+	var policy lirsPolicy
+	policy.stack = list.New()
+	policy.queue = list.New()
+	policy.ghosts = list.New()
+	policy.entries = make(map[string]*lirsEntry)
+	hirs := min(max(int(float64(limit)*hirRatio), 1), limit)
+	policy.maxLIRs = max(limit-hirs, 1)
+	policy.limit = limit
+	return &policy
+}
+
+func (policy *lirsPolicy) OnHit(key string) {
+	entry, ok := policy.entries[key]
+	if !ok || entry.state == lirsGhost {
+		return
+	}
+	if entry.state == lirsLIR {
+		policy.stack.MoveToFront(entry.s)
+		policy.prune()
+		return
+	}
+	if entry.s != nil {
+		// A resident HIR key used again while still in S
+		// has a lower recency than the LIR key at the bottom.
+		policy.queue.Remove(entry.q)
+		entry.q = nil
+		policy.stack.MoveToFront(entry.s)
+		policy.promote(entry)
+		return
+	}
+	entry.s = policy.stack.PushFront(entry)
+	policy.queue.MoveToBack(entry.q)
+}
+
+func (policy *lirsPolicy) OnMiss(key string) {}
+
+func (policy *lirsPolicy) OnInsert(key string) {
+	if entry, ok := policy.entries[key]; ok && entry.state == lirsGhost {
+		policy.ghosts.Remove(entry.ghost)
+		entry.ghost = nil
+		policy.stack.MoveToFront(entry.s)
+		policy.promote(entry)
+		return
+	}
+	entry := &lirsEntry{key: key}
+	policy.entries[key] = entry
+	entry.s = policy.stack.PushFront(entry)
+	if policy.lirs < policy.maxLIRs {
+		entry.state = lirsLIR
+		policy.lirs++
+		return
+	}
+	entry.state = lirsHIR
+	entry.q = policy.queue.PushBack(entry)
+}
+
+// promote makes entry, at the top of S, a LIR key, demoting the LIR key
+// at the bottom of S to a resident HIR key if there are too many.
+func (policy *lirsPolicy) promote(entry *lirsEntry) {
+	entry.state = lirsLIR
+	policy.lirs++
+	if policy.lirs > policy.maxLIRs {
+		policy.demote()
+	}
+	policy.prune()
+}
+
+// demote turns the LIR key at the bottom of S into a resident HIR key at the end of Q.
+func (policy *lirsPolicy) demote() {
+	policy.prune()
+	back := policy.stack.Back()
+	if back == nil {
+		return
+	}
+	entry := back.Value.(*lirsEntry)
+	policy.stack.Remove(back)
+	entry.s = nil
+	entry.state = lirsHIR
+	entry.q = policy.queue.PushBack(entry)
+	policy.lirs--
+	policy.prune()
+}
+
+// prune pops HIR keys off the bottom of S until a LIR key is there,
+// forgetting the non-resident ones.
+func (policy *lirsPolicy) prune() {
+	for back := policy.stack.Back(); back != nil; back = policy.stack.Back() {
+		entry := back.Value.(*lirsEntry)
+		if entry.state == lirsLIR {
+			return
+		}
+		policy.stack.Remove(back)
+		entry.s = nil
+		if entry.state == lirsGhost {
+			policy.ghosts.Remove(entry.ghost)
+			delete(policy.entries, entry.key)
+		}
+	}
+}
+
+func (policy *lirsPolicy) Victim() (key string, ok bool) {
+	if policy.queue.Len() == 0 {
+		if policy.lirs == 0 {
+			return "", false
+		}
+		policy.demote()
+	}
+	entry := policy.queue.Remove(policy.queue.Front()).(*lirsEntry)
+	entry.q = nil
+	if entry.s == nil {
+		delete(policy.entries, entry.key)
+		return entry.key, true
+	}
+	entry.state = lirsGhost
+	entry.ghost = policy.ghosts.PushBack(entry)
+	// Remember no more non-resident keys than the cache holds.
+	if policy.ghosts.Len() > policy.limit {
+		oldest := policy.ghosts.Remove(policy.ghosts.Front()).(*lirsEntry)
+		policy.stack.Remove(oldest.s)
+		delete(policy.entries, oldest.key)
+	}
+	return entry.key, true
+}
+
+func (policy *lirsPolicy) Remove(key string) {
+	entry, ok := policy.entries[key]
+	if !ok {
+		return
+	}
+	if entry.s != nil {
+		policy.stack.Remove(entry.s)
+	}
+	if entry.q != nil {
+		policy.queue.Remove(entry.q)
+	}
+	if entry.ghost != nil {
+		policy.ghosts.Remove(entry.ghost)
+	}
+	if entry.state == lirsLIR {
+		policy.lirs--
+	}
+	delete(policy.entries, key)
+	policy.prune()
+}
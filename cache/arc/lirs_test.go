@@ -0,0 +1,137 @@
+package arc
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// Computes hit ratio for accessing random entries in a LIRS cache
+func BenchmarkLIRS_Rand(b *testing.B) {
+	l, err := NewPolicyCache("lirs", 8192)
+	if err != nil {
+		b.Fatalf("err: %v", err)
+	}
+
+	trace := make([]int64, b.N*2)
+	for i := 0; i < b.N*2; i++ {
+		trace[i] = rand.Int63() % 32768
+	}
+
+	b.ResetTimer()
+
+	for i := 0; i < 2*b.N; i++ {
+		s := fmt.Sprintf("%v", trace[i])
+		if i%2 == 0 {
+			b := make([]byte, 8)
+			binary.LittleEndian.PutUint64(b, uint64(trace[i]))
+
+			l.Set(s, b)
+		} else {
+			l.Get(s)
+		}
+	}
+	hits := l.stats.Hits
+	misses := l.stats.Misses
+	b.Logf("hit: %d miss: %d ratio: %f", hits, misses, float64(hits)/float64(misses))
+}
+
+// Compute hit ratio for a linear sequence of accesses
+func BenchmarkLIRS_Freq(b *testing.B) {
+	l, err := NewPolicyCache("lirs", 8192)
+	if err != nil {
+		b.Fatalf("err: %v", err)
+	}
+
+	trace := make([]int64, b.N*2)
+	for i := 0; i < b.N*2; i++ {
+		if i%2 == 0 {
+			trace[i] = rand.Int63() % 16384
+		} else {
+			trace[i] = rand.Int63() % 32768
+		}
+	}
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		b := make([]byte, 8)
+		binary.LittleEndian.PutUint64(b, uint64(trace[i]))
+		s := fmt.Sprintf("%v", trace[i])
+
+		l.Set(s, b)
+	}
+	for i := 0; i < b.N; i++ {
+		s := fmt.Sprintf("%v", trace[i])
+		l.Get(s)
+	}
+	hits := l.stats.Hits
+	misses := l.stats.Misses
+	b.Logf("hit: %d miss: %d ratio: %f", hits, misses, float64(hits)/float64(misses))
+}
+
+// Tests that LIRS keeps most of a loop larger than the cache, where LRU and ARC keep none
+func TestLIRS_Loop(t *testing.T) {
+	trace, _ := NewTrace("loop", 100000, 1200, 1)
+	lirs, _ := Simulate("lirs", 1000, trace)
+	lru, _ := Simulate("lru", 1000, trace)
+	arc, _ := Simulate("arc", 1000, trace)
+	t.Logf("loop: lru %.4f arc %.4f lirs %.4f", lru.HitRatio(), arc.HitRatio(), lirs.HitRatio())
+	if lirs.HitRatio() < 0.7 || lru.Hits != 0 {
+		t.Fatalf("lirs %.4f on a loop", lirs.HitRatio())
+	}
+
+	zipf, _ := NewTrace("zipf", 100000, 10000, 1)
+	lirs, _ = Simulate("lirs", 1000, zipf)
+	lru, _ = Simulate("lru", 1000, zipf)
+	t.Logf("zipf: lru %.4f lirs %.4f", lru.HitRatio(), lirs.HitRatio())
+	if lirs.HitRatio() < lru.HitRatio() {
+		t.Fatalf("lirs %.4f below lru %.4f on zipf", lirs.HitRatio(), lru.HitRatio())
+	}
+}
+
+// lirsAfter returns a LIRS policy for three entries after replaying trace.
+func lirsAfter(trace ...string) *lirsPolicy {
+	policy := newLIRSPolicy(3, 0.34)
+	SimulatePolicy(policy, 3, trace)
+	return policy
+}
+
+// Tests the LIR/HIR states, stack pruning and non-resident HIR keys
+func TestLIRS_States(t *testing.T) {
+	// Two LIR keys and one resident HIR key
+	policy := lirsAfter("a", "b", "c")
+	if policy.entries["a"].state != lirsLIR || policy.entries["b"].state != lirsLIR || policy.entries["c"].state != lirsHIR {
+		t.Fatalf("bad states after warm-up")
+	}
+	// d evicts c, which stays in S as a non-resident HIR key
+	policy = lirsAfter("a", "b", "c", "d")
+	if entry := policy.entries["c"]; entry == nil || entry.state != lirsGhost || entry.s == nil {
+		t.Fatalf("c should be a non-resident HIR key in S")
+	}
+	// c returns while in S, so it becomes LIR and the bottom LIR key, a, is demoted;
+	// the resident HIR key d is evicted to make room
+	policy = lirsAfter("a", "b", "c", "d", "c")
+	if policy.entries["c"].state != lirsLIR || policy.entries["a"].state != lirsHIR {
+		t.Fatalf("c should be promoted and a demoted")
+	}
+	if entry := policy.entries["d"]; entry == nil || entry.state != lirsGhost {
+		t.Fatalf("d should be a non-resident HIR key")
+	}
+	if back := policy.stack.Back().Value.(*lirsEntry); back.state != lirsLIR {
+		t.Fatalf("bottom of S is not LIR after pruning")
+	}
+	if policy.lirs != 2 || policy.queue.Len() != 1 {
+		t.Fatalf("bad counts: %d lir, %d in q", policy.lirs, policy.queue.Len())
+	}
+
+	policy.Remove("c")
+	policy.Remove("a")
+	if policy.lirs != 1 || policy.queue.Len() != 0 {
+		t.Fatalf("bad counts after remove: %d lir, %d in q", policy.lirs, policy.queue.Len())
+	}
+	if _, err := NewLIRS(4, 1); err == nil {
+		t.Fatalf("bad hir ratio accepted")
+	}
+}
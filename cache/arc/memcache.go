@@ -0,0 +1,372 @@
+package arc
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Limits of the memcached text protocol as served by MemcacheServer.
+const (
+	maxMemcacheKeyLen = 250
+	maxMemcacheItem   = 1 << 20
+	// Exptimes over 30 days are absolute Unix times rather than relative.
+	maxRelativeExptime = 60 * 60 * 24 * 30
+)
+
+// Values are stored in the cache as an item header holding the client's
+// flags and the item's CAS unique, followed by the data.
+const itemHeaderLen = 12
+
+// A MemcacheServer serves a SyncARC over the memcached ASCII protocol,
+// so that memcached clients can use ARC eviction.
+// Each connection is served by a goroutine of its own.
+type MemcacheServer struct {
+	connServer
+	cache   *SyncARC
+	started time.Time
+	// cas is the last CAS unique handed out.
+	cas uint64
+
+	cmdGet, cmdSet, cmdTouch       int64
+	cmdFlush, touchHits, touchMiss int64
+}
+
+// NewMemcacheServer returns a MemcacheServer serving cache.
+func NewMemcacheServer(cache *SyncARC) *MemcacheServer {
+	var server MemcacheServer
+	server.connServer.init(server.handleConn)
+	server.cache = cache
+	server.started = time.Now()
+	return &server
+}
+
+// handleConn reads and answers commands on conn until it is closed or the client quits.
+func (server *MemcacheServer) handleConn(conn net.Conn) {
+	reader := bufio.NewReader(conn)
+	writer := bufio.NewWriter(conn)
+	for {
+		line, err := reader.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+			writer.WriteString("CLIENT_ERROR line too long\r\n")
+			writer.Flush()
+			return
+		}
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(string(line))
+		if len(fields) == 0 {
+			writer.WriteString("ERROR\r\n")
+		} else if quit := server.dispatch(fields, reader, writer); quit {
+			writer.Flush()
+			return
+		}
+		if err := writer.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// dispatch runs the command in fields, reading any data block from reader
+// and writing the reply to writer. It reports whether the connection should close.
+func (server *MemcacheServer) dispatch(fields []string, reader *bufio.Reader, writer *bufio.Writer) (quit bool) {
+	switch fields[0] {
+	case "get", "gets":
+		server.get(fields, writer)
+	case "set", "add", "replace":
+		return server.store(fields, reader, writer)
+	case "delete":
+		server.delete(fields, writer)
+	case "touch":
+		server.touch(fields, writer)
+	case "stats":
+		server.stats(fields, writer)
+	case "flush_all":
+		server.flushAll(fields, writer)
+	case "version":
+		writer.WriteString("VERSION arc\r\n")
+	case "quit":
+		return true
+	default:
+		writer.WriteString("ERROR\r\n")
+	}
+	return false
+}
+
+// get answers "get <key>*" and "gets <key>*".
+func (server *MemcacheServer) get(fields []string, writer *bufio.Writer) {
+	if len(fields) < 2 {
+		writer.WriteString("ERROR\r\n")
+		return
+	}
+	keys := fields[1:]
+	atomic.AddInt64(&server.cmdGet, int64(len(keys)))
+	values := server.cache.GetMany(keys)
+	for _, key := range keys {
+		value, ok := values[key]
+		if !ok {
+			continue
+		}
+		flags, cas, data, ok := decodeItem(value)
+		if !ok {
+			continue
+		}
+		if fields[0] == "gets" {
+			fmt.Fprintf(writer, "VALUE %s %d %d %d\r\n", key, flags, len(data), cas)
+		} else {
+			fmt.Fprintf(writer, "VALUE %s %d %d\r\n", key, flags, len(data))
+		}
+		writer.Write(data)
+		writer.WriteString("\r\n")
+	}
+	writer.WriteString("END\r\n")
+}
+
+// store answers "set|add|replace <key> <flags> <exptime> <bytes> [noreply]".
+func (server *MemcacheServer) store(fields []string, reader *bufio.Reader, writer *bufio.Writer) (quit bool) {
+	if len(fields) != 5 && len(fields) != 6 {
+		writer.WriteString("ERROR\r\n")
+		return false
+	}
+	key := fields[1]
+	flags, flagsErr := strconv.ParseUint(fields[2], 10, 32)
+	exptime, exptimeErr := strconv.ParseInt(fields[3], 10, 64)
+	size, sizeErr := strconv.Atoi(fields[4])
+	noreply := len(fields) == 6 && fields[5] == "noreply"
+	if sizeErr != nil || size < 0 {
+		writer.WriteString("CLIENT_ERROR bad command line format\r\n")
+		// The length of the data block is unknown, so the stream cannot be resynchronized.
+		return true
+	}
+	if flagsErr != nil || exptimeErr != nil {
+		if _, err := reader.Discard(size + 2); err != nil {
+			return true
+		}
+		writer.WriteString("CLIENT_ERROR bad command line format\r\n")
+		return false
+	}
+
+	if size > maxMemcacheItem {
+		if _, err := reader.Discard(size + 2); err != nil {
+			return true
+		}
+		writer.WriteString("SERVER_ERROR object too large for cache\r\n")
+		return false
+	}
+	data := make([]byte, size+2)
+	if _, err := io.ReadFull(reader, data); err != nil {
+		return true
+	}
+	if data[size] != '\r' || data[size+1] != '\n' {
+		// Skip the rest of the oversized chunk.
+		if data[size+1] != '\n' {
+			if _, err := reader.ReadSlice('\n'); err != nil {
+				return true
+			}
+		}
+		writer.WriteString("CLIENT_ERROR bad data chunk\r\n")
+		return false
+	}
+	data = data[:size]
+	if len(key) > maxMemcacheKeyLen {
+		writer.WriteString("CLIENT_ERROR key too long\r\n")
+		return false
+	}
+
+	atomic.AddInt64(&server.cmdSet, 1)
+	ttl, expired := exptimeToTTL(exptime)
+	item := encodeItem(uint32(flags), atomic.AddUint64(&server.cas, 1), data)
+	reply := "STORED\r\n"
+	server.cache.Do(func(arc *ARC) {
+		arc.dropIfExpired(key)
+		_, exists := arc.CheckCache(key)
+		if (fields[0] == "add" && exists) || (fields[0] == "replace" && !exists) {
+			reply = "NOT_STORED\r\n"
+			return
+		}
+		switch err := arc.TrySet(key, item, ttl); {
+		case err == ErrNotAdmitted:
+			reply = "NOT_STORED\r\n"
+			return
+		case err != nil:
+			reply = "SERVER_ERROR unable to store object\r\n"
+			return
+		}
+		if expired {
+			arc.Expire(key, -1)
+		}
+	})
+	if !noreply {
+		writer.WriteString(reply)
+	}
+	return false
+}
+
+// delete answers "delete <key> [0] [noreply]".
+func (server *MemcacheServer) delete(fields []string, writer *bufio.Writer) {
+	if len(fields) < 2 || len(fields) > 4 {
+		writer.WriteString("ERROR\r\n")
+		return
+	}
+	noreply := fields[len(fields)-1] == "noreply"
+	var ok bool
+	server.cache.Do(func(arc *ARC) {
+		arc.dropIfExpired(fields[1])
+		// Remove also drops a ghost, but a ghost is not cached, as get answers.
+		_, ok = arc.CheckCache(fields[1])
+		arc.Remove(fields[1])
+	})
+	if noreply {
+		return
+	}
+	if ok {
+		writer.WriteString("DELETED\r\n")
+	} else {
+		writer.WriteString("NOT_FOUND\r\n")
+	}
+}
+
+// touch answers "touch <key> <exptime> [noreply]".
+func (server *MemcacheServer) touch(fields []string, writer *bufio.Writer) {
+	if len(fields) != 3 && len(fields) != 4 {
+		writer.WriteString("ERROR\r\n")
+		return
+	}
+	exptime, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		writer.WriteString("CLIENT_ERROR invalid exptime argument\r\n")
+		return
+	}
+	atomic.AddInt64(&server.cmdTouch, 1)
+	ttl, expired := exptimeToTTL(exptime)
+	if expired {
+		ttl = -1
+	}
+	ok := server.cache.Expire(fields[1], ttl)
+	if ok {
+		atomic.AddInt64(&server.touchHits, 1)
+	} else {
+		atomic.AddInt64(&server.touchMiss, 1)
+	}
+	if len(fields) == 4 && fields[3] == "noreply" {
+		return
+	}
+	if ok {
+		writer.WriteString("TOUCHED\r\n")
+	} else {
+		writer.WriteString("NOT_FOUND\r\n")
+	}
+}
+
+// stats answers "stats", including the ARC's list sizes and target marker.
+func (server *MemcacheServer) stats(fields []string, writer *bufio.Writer) {
+	if len(fields) > 1 {
+		// Only general-purpose statistics are supported.
+		writer.WriteString("END\r\n")
+		return
+	}
+	var t1, t2, b1, b2, targetMarker, limit int
+	var stats Stats
+	var diskStats DiskStats
+	server.cache.Do(func(arc *ARC) {
+		t1, t2, b1, b2 = arc.ListLens()
+		targetMarker = arc.TargetMarker()
+		limit = arc.MaxEntries()
+		stats = *arc.Stats()
+		diskStats = *arc.DiskStats()
+	})
+	now := time.Now()
+	stat := func(name string, value interface{}) {
+		fmt.Fprintf(writer, "STAT %s %v\r\n", name, value)
+	}
+	stat("pid", os.Getpid())
+	stat("uptime", int64(now.Sub(server.started).Seconds()))
+	stat("time", now.Unix())
+	stat("version", "arc")
+	stat("curr_connections", atomic.LoadInt64(&server.currConns))
+	stat("total_connections", atomic.LoadInt64(&server.totalConns))
+	stat("cmd_get", atomic.LoadInt64(&server.cmdGet))
+	stat("cmd_set", atomic.LoadInt64(&server.cmdSet))
+	stat("cmd_flush", atomic.LoadInt64(&server.cmdFlush))
+	stat("cmd_touch", atomic.LoadInt64(&server.cmdTouch))
+	stat("get_hits", stats.Hits)
+	stat("get_misses", stats.Misses)
+	stat("touch_hits", atomic.LoadInt64(&server.touchHits))
+	stat("touch_misses", atomic.LoadInt64(&server.touchMiss))
+	stat("curr_items", t1+t2)
+	stat("limit_items", limit)
+	stat("arc_t1_items", t1)
+	stat("arc_t2_items", t2)
+	stat("arc_b1_items", b1)
+	stat("arc_b2_items", b2)
+	stat("arc_target_marker", targetMarker)
+	stat("arc_disk_bytes", diskStats.UsedBytes)
+	writer.WriteString("END\r\n")
+}
+
+// flushAll answers "flush_all [delay] [noreply]".
+func (server *MemcacheServer) flushAll(fields []string, writer *bufio.Writer) {
+	noreply := fields[len(fields)-1] == "noreply"
+	if noreply {
+		fields = fields[:len(fields)-1]
+	}
+	var delay int64
+	if len(fields) == 2 {
+		var err error
+		if delay, err = strconv.ParseInt(fields[1], 10, 64); err != nil || delay < 0 {
+			writer.WriteString("CLIENT_ERROR bad command line format\r\n")
+			return
+		}
+	}
+	atomic.AddInt64(&server.cmdFlush, 1)
+	if delay > 0 {
+		time.AfterFunc(time.Duration(delay)*time.Second, server.cache.Purge)
+	} else {
+		server.cache.Purge()
+	}
+	if !noreply {
+		writer.WriteString("OK\r\n")
+	}
+}
+
+// exptimeToTTL converts a memcached exptime to a TTL for SetWithTTL.
+// expired is true if the item should expire at once.
+func exptimeToTTL(exptime int64) (ttl time.Duration, expired bool) {
+	switch {
+	case exptime == 0:
+		return 0, false
+	case exptime < 0:
+		return 0, true
+	case exptime <= maxRelativeExptime:
+		return time.Duration(exptime) * time.Second, false
+	}
+	ttl = time.Until(time.Unix(exptime, 0))
+	return ttl, ttl <= 0
+}
+
+// encodeItem returns the value stored in the cache for a memcached item.
+func encodeItem(flags uint32, cas uint64, data []byte) []byte {
+	item := make([]byte, itemHeaderLen+len(data))
+	binary.BigEndian.PutUint32(item, flags)
+	binary.BigEndian.PutUint64(item[4:], cas)
+	copy(item[itemHeaderLen:], data)
+	return item
+}
+
+// decodeItem reverses encodeItem. ok is false if value is not an item.
+func decodeItem(value []byte) (flags uint32, cas uint64, data []byte, ok bool) {
+	if len(value) < itemHeaderLen {
+		return 0, 0, nil, false
+	}
+	flags = binary.BigEndian.Uint32(value)
+	cas = binary.BigEndian.Uint64(value[4:])
+	return flags, cas, value[itemHeaderLen:], true
+}
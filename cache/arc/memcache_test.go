@@ -0,0 +1,187 @@
+package arc
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// startMemcacheServer serves a new SyncARC over loopback and returns its address.
+func startMemcacheServer(t *testing.T, limit int, opts ...Option) (*MemcacheServer, string) {
+	cache, err := NewSyncARC(limit, append([]Option{WithDirectory(t.TempDir())}, opts...)...)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	server := NewMemcacheServer(cache)
+	go server.Serve(listener)
+	t.Cleanup(func() { server.Close() })
+	return server, listener.Addr().String()
+}
+
+// memcacheClient sends raw protocol lines and reads replies.
+type memcacheClient struct {
+	t      *testing.T
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func dialMemcache(t *testing.T, addr string) *memcacheClient {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return &memcacheClient{t, conn, bufio.NewReader(conn)}
+}
+
+// call sends request and returns the reply lines up to and including one of ends.
+func (client *memcacheClient) call(request string, ends ...string) []string {
+	if _, err := client.conn.Write([]byte(request)); err != nil {
+		client.t.Fatalf("err: %v", err)
+	}
+	var lines []string
+	for {
+		line, err := client.reader.ReadString('\n')
+		if err != nil {
+			client.t.Fatalf("err after %q: %v", lines, err)
+		}
+		line = strings.TrimRight(line, "\r\n")
+		lines = append(lines, line)
+		for _, end := range ends {
+			if line == end || strings.HasPrefix(line, end) {
+				return lines
+			}
+		}
+	}
+}
+
+func (client *memcacheClient) expect(request string, want ...string) {
+	got := client.call(request, want[len(want)-1])
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		client.t.Fatalf("%q: got %q, want %q", request, got, want)
+	}
+}
+
+// Tests the storage, retrieval and deletion commands
+func TestMemcache_Commands(t *testing.T) {
+	_, addr := startMemcacheServer(t, 16)
+	client := dialMemcache(t, addr)
+
+	client.expect("set a 5 0 3\r\nabc\r\n", "STORED")
+	client.expect("get a\r\n", "VALUE a 5 3", "abc", "END")
+	client.expect("get a missing\r\n", "VALUE a 5 3", "abc", "END")
+	client.expect("add a 0 0 1\r\nx\r\n", "NOT_STORED")
+	client.expect("replace missing 0 0 1\r\nx\r\n", "NOT_STORED")
+	client.expect("add b 0 0 2\r\nhi\r\n", "STORED")
+	client.expect("replace b 1 0 3\r\nbye\r\n", "STORED")
+
+	lines := client.call("gets b\r\n", "END")
+	var flags, size int
+	var cas uint64
+	if _, err := fmt.Sscanf(lines[0], "VALUE b %d %d %d", &flags, &size, &cas); err != nil || flags != 1 || size != 3 || cas == 0 {
+		t.Fatalf("bad gets reply: %q", lines)
+	}
+
+	client.expect("delete b\r\n", "DELETED")
+	client.expect("delete b\r\n", "NOT_FOUND")
+	client.expect("get b\r\n", "END")
+
+	client.expect("touch a 100\r\n", "TOUCHED")
+	client.expect("touch missing 100\r\n", "NOT_FOUND")
+	client.expect("touch a -1\r\n", "TOUCHED")
+	client.expect("get a\r\n", "END")
+	client.expect("set gone 0 -1 1\r\nx\r\n", "STORED")
+	client.expect("get gone\r\n", "END")
+
+	client.expect("set quiet 0 0 1 noreply\r\nq\r\n"+"get quiet\r\n", "VALUE quiet 0 1", "q", "END")
+	client.expect("set bad 0 0 1\r\nxyz\r\n", "CLIENT_ERROR bad data chunk")
+	client.expect("bogus\r\n", "ERROR")
+	client.expect("version\r\n", "VERSION arc")
+
+	client.expect("flush_all\r\n", "OK")
+	client.expect("get quiet\r\n", "END")
+}
+
+// Tests that a key turned away by the admission filter is not stored, without an error
+func TestMemcache_NotAdmitted(t *testing.T) {
+	_, addr := startMemcacheServer(t, 16, WithAdmissionFilter(0.001))
+	client := dialMemcache(t, addr)
+
+	client.expect("set a 0 0 1\r\nx\r\n", "NOT_STORED")
+	client.expect("get a\r\n", "END")
+	client.expect("set a 0 0 1\r\nx\r\n", "STORED")
+}
+
+// Tests that delete answers NOT_FOUND for a ghost, which get does not find
+func TestMemcache_DeleteGhost(t *testing.T) {
+	_, addr := startMemcacheServer(t, 2)
+	client := dialMemcache(t, addr)
+
+	client.expect("set a 0 0 1\r\n1\r\n", "STORED")
+	client.expect("get a\r\n", "VALUE a 0 1", "1", "END")
+	client.expect("set b 0 0 1\r\n2\r\n", "STORED")
+	// Evicts b from T1 into B1
+	client.expect("set c 0 0 1\r\n3\r\n", "STORED")
+	client.expect("delete b\r\n", "NOT_FOUND")
+}
+
+// Tests that stats report the ARC's lists and target marker
+func TestMemcache_Stats(t *testing.T) {
+	_, addr := startMemcacheServer(t, 2)
+	client := dialMemcache(t, addr)
+
+	client.expect("set a 0 0 1\r\na\r\n", "STORED")
+	client.expect("get a\r\n", "VALUE a 0 1", "a", "END")
+	client.expect("set b 0 0 1\r\nb\r\n", "STORED")
+	// Evicts b into B1
+	client.expect("set c 0 0 1\r\nc\r\n", "STORED")
+
+	stats := make(map[string]string)
+	for _, line := range client.call("stats\r\n", "END") {
+		fields := strings.Fields(line)
+		if len(fields) == 3 && fields[0] == "STAT" {
+			stats[fields[1]] = fields[2]
+		}
+	}
+	want := map[string]string{
+		"arc_t1_items":      "1",
+		"arc_t2_items":      "1",
+		"arc_b1_items":      "1",
+		"arc_b2_items":      "0",
+		"arc_target_marker": "0",
+		"curr_items":        "2",
+		"get_hits":          "1",
+		"cmd_set":           "3",
+	}
+	for name, value := range want {
+		if stats[name] != value {
+			t.Fatalf("bad %s: %q, want %q", name, stats[name], value)
+		}
+	}
+}
+
+// Tests several clients using the server at once
+func TestMemcache_Concurrent(t *testing.T) {
+	_, addr := startMemcacheServer(t, 1024)
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		client := dialMemcache(t, addr)
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				key := fmt.Sprintf("%v-%v", g, i)
+				client.expect(fmt.Sprintf("set %s 0 0 %d\r\n%s\r\n", key, len(key), key), "STORED")
+				client.expect("get "+key+"\r\n", fmt.Sprintf("VALUE %s 0 %d", key, len(key)), key, "END")
+			}
+		}(g)
+	}
+	wg.Wait()
+}
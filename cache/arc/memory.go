@@ -0,0 +1,171 @@
+package arc
+
+import (
+	"errors"
+	"runtime/metrics"
+	"sync"
+	"time"
+)
+
+// A MemoryPolicy configures a MemoryController. Fields left zero take the defaults given.
+type MemoryPolicy struct {
+	// MinEntries and MaxEntries bound the capacity the controller sets.
+	MinEntries int
+	MaxEntries int
+	// Memory pressure is the live heap as a fraction of MemoryLimit, if it is set.
+	// Otherwise it is the live heap as a fraction of the heap goal, which nears 1
+	// as the collector is squeezed by a soft memory limit (see runtime/debug.SetMemoryLimit).
+	MemoryLimit uint64
+	// The cache shrinks while pressure is above High (default 0.9) and grows while it
+	// is below Low (default 0.6); in between, it is left alone.
+	High float64
+	Low  float64
+	// Step is the fraction of its capacity by which the cache is resized at a time (default 0.1).
+	Step float64
+	// Interval is the time between two readings of the memory pressure (default 1s).
+	Interval time.Duration
+	// OnResize, if set, is called after every resize, from the goroutine that made it.
+	OnResize func(event ResizeEvent)
+}
+
+// A ResizeEvent reports a change of capacity made by a MemoryController.
+type ResizeEvent struct {
+	// Pressure is the memory pressure that led to the resize.
+	Pressure float64
+	OldLimit int
+	NewLimit int
+}
+
+// A MemoryController resizes a SyncARC between the bounds of its policy as the
+// memory pressure of the process changes, so that a cache in a memory-constrained
+// container gives memory back before the process runs out.
+// The live heap is only measured by a garbage collection, so the controller
+// resizes the cache at most once per collection.
+type MemoryController struct {
+	cache  *SyncARC
+	policy MemoryPolicy
+	// read returns the memory pressure and the number of completed collections.
+	read func() (pressure float64, cycles uint64)
+	// mu guards lastCycles, the collections completed at the last resize.
+	mu         sync.Mutex
+	lastCycles uint64
+	done       chan struct{}
+	wg         sync.WaitGroup
+}
+
+// NewMemoryController returns a controller that resizes cache according to policy,
+// from a goroutine that runs until Close is called. The capacity of the cache
+// is first brought within the policy's bounds.
+func NewMemoryController(cache *SyncARC, policy MemoryPolicy) (*MemoryController, error) {
+	if policy.MinEntries <= 0 || policy.MaxEntries < policy.MinEntries {
+		return nil, errors.New("arc: memory policy needs 0 < MinEntries <= MaxEntries")
+	}
+	if policy.High == 0 {
+		policy.High = 0.9
+	}
+	if policy.Low == 0 {
+		policy.Low = 0.6
+	}
+	if policy.Low >= policy.High {
+		return nil, errors.New("arc: memory policy needs Low < High")
+	}
+	if policy.Step <= 0 {
+		policy.Step = 0.1
+	}
+	if policy.Interval <= 0 {
+		policy.Interval = time.Second
+	}
+	var controller MemoryController
+	controller.cache = cache
+	controller.policy = policy
+	controller.read = controller.readMetrics
+	controller.done = make(chan struct{})
+	limit := cache.MaxEntries()
+	if bounded := min(max(limit, policy.MinEntries), policy.MaxEntries); bounded != limit {
+		cache.Resize(bounded)
+	}
+	controller.wg.Add(1)
+	go controller.adjustEvery(policy.Interval)
+	return &controller, nil
+}
+
+// adjustEvery calls Adjust every interval until Close is called.
+func (controller *MemoryController) adjustEvery(interval time.Duration) {
+	defer controller.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			controller.Adjust()
+		case <-controller.done:
+			return
+		}
+	}
+}
+
+// Close stops the controller, leaving the cache at its current capacity.
+func (controller *MemoryController) Close() {
+	select {
+	case <-controller.done:
+	default:
+		close(controller.done)
+	}
+	controller.wg.Wait()
+}
+
+// Adjust reads the memory pressure and resizes the cache by one step if it is
+// outside the policy's band and a collection has completed since the last resize.
+// It is called every Interval, and may also be called at any time.
+func (controller *MemoryController) Adjust() (event ResizeEvent, resized bool) {
+	controller.mu.Lock()
+	pressure, cycles := controller.read()
+	policy := controller.policy
+	limit := controller.cache.MaxEntries()
+	newLimit := limit
+	step := max(int(float64(limit)*policy.Step), 1)
+	switch {
+	case cycles == controller.lastCycles:
+		// The pressure has not been measured since the last resize.
+	case pressure > policy.High:
+		newLimit = max(limit-step, policy.MinEntries)
+	case pressure < policy.Low:
+		newLimit = min(limit+step, policy.MaxEntries)
+	}
+	if newLimit == limit {
+		controller.mu.Unlock()
+		return ResizeEvent{}, false
+	}
+	controller.cache.Resize(newLimit)
+	controller.lastCycles = cycles
+	controller.mu.Unlock()
+
+	event = ResizeEvent{Pressure: pressure, OldLimit: limit, NewLimit: newLimit}
+	if policy.OnResize != nil {
+		policy.OnResize(event)
+	}
+	return event, true
+}
+
+// The runtime metrics a MemoryController reads.
+const (
+	metricHeapLive = "/gc/heap/live:bytes"
+	metricHeapGoal = "/gc/heap/goal:bytes"
+	metricGCCycles = "/gc/cycles/total:gc-cycles"
+)
+
+// readMetrics returns the memory pressure of the process, as defined by
+// MemoryPolicy, and the number of completed collections.
+func (controller *MemoryController) readMetrics() (pressure float64, cycles uint64) {
+	samples := []metrics.Sample{{Name: metricHeapLive}, {Name: metricHeapGoal}, {Name: metricGCCycles}}
+	metrics.Read(samples)
+	live := samples[0].Value.Uint64()
+	limit := controller.policy.MemoryLimit
+	if limit == 0 {
+		limit = samples[1].Value.Uint64()
+	}
+	if limit == 0 {
+		return 0, samples[2].Value.Uint64()
+	}
+	return float64(live) / float64(limit), samples[2].Value.Uint64()
+}
@@ -0,0 +1,110 @@
+package arc
+
+import (
+	"fmt"
+	"runtime"
+	"testing"
+)
+
+// A fakeMemory stands in for the runtime metrics read by a MemoryController.
+type fakeMemory struct {
+	pressure float64
+	cycles   uint64
+}
+
+func (memory *fakeMemory) read() (float64, uint64) {
+	return memory.pressure, memory.cycles
+}
+
+// Returns a controller for a full SyncARC of 100 entries, driven by memory
+func newFakeController(t *testing.T, memory *fakeMemory, events *[]ResizeEvent) (*MemoryController, *SyncARC) {
+	sarc, err := NewSyncARC(100, WithDirectory(t.TempDir()))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		sarc.Set(fmt.Sprint(i), []byte("v"))
+	}
+	policy := MemoryPolicy{MinEntries: 50, MaxEntries: 120, OnResize: func(event ResizeEvent) {
+		*events = append(*events, event)
+	}}
+	controller, err := NewMemoryController(sarc, policy)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	controller.Close()
+	controller.read = memory.read
+	return controller, sarc
+}
+
+// Tests that a MemoryController shrinks and grows a cache within its bounds
+func TestMemoryController(t *testing.T) {
+	memory := &fakeMemory{pressure: 0.95}
+	var events []ResizeEvent
+	controller, sarc := newFakeController(t, memory, &events)
+
+	for i := 0; i < 10; i++ {
+		memory.cycles++
+		controller.Adjust()
+	}
+	if sarc.MaxEntries() != 50 || sarc.Len() != 50 {
+		t.Fatalf("bad shrink: limit %d, %d entries", sarc.MaxEntries(), sarc.Len())
+	}
+	if len(events) != 7 || events[0] != (ResizeEvent{Pressure: 0.95, OldLimit: 100, NewLimit: 90}) {
+		t.Fatalf("bad events: %+v", events)
+	}
+
+	// Within the band, nothing changes
+	memory.pressure = 0.7
+	memory.cycles++
+	if _, resized := controller.Adjust(); resized {
+		t.Fatalf("resized within the band")
+	}
+	memory.pressure = 0.1
+	for i := 0; i < 20; i++ {
+		memory.cycles++
+		controller.Adjust()
+	}
+	if sarc.MaxEntries() != 120 {
+		t.Fatalf("bad grow: limit %d", sarc.MaxEntries())
+	}
+}
+
+// Tests that a MemoryController waits for a collection between two resizes
+func TestMemoryController_OncePerCycle(t *testing.T) {
+	memory := &fakeMemory{pressure: 0.95, cycles: 1}
+	var events []ResizeEvent
+	controller, sarc := newFakeController(t, memory, &events)
+	controller.Adjust()
+	controller.Adjust()
+	if sarc.MaxEntries() != 90 || len(events) != 1 {
+		t.Fatalf("resized %d times without a collection", len(events))
+	}
+}
+
+// Tests the policy checks, and that the runtime metrics give a pressure
+func TestMemoryController_Policy(t *testing.T) {
+	sarc, err := NewSyncARC(200, WithDirectory(t.TempDir()))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for _, policy := range []MemoryPolicy{{}, {MinEntries: 10, MaxEntries: 5}, {MinEntries: 1, MaxEntries: 5, Low: 0.9, High: 0.5}} {
+		if _, err := NewMemoryController(sarc, policy); err == nil {
+			t.Fatalf("bad policy accepted: %+v", policy)
+		}
+	}
+	controller, err := NewMemoryController(sarc, MemoryPolicy{MinEntries: 10, MaxEntries: 100})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer controller.Close()
+	if sarc.MaxEntries() != 100 {
+		t.Fatalf("limit not brought within bounds: %d", sarc.MaxEntries())
+	}
+	runtime.GC()
+	pressure, cycles := controller.readMetrics()
+	t.Logf("pressure %.3f after %d collections", pressure, cycles)
+	if pressure <= 0 || pressure > 1 || cycles == 0 {
+		t.Fatalf("bad reading: %.3f, %d", pressure, cycles)
+	}
+}
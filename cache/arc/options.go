@@ -0,0 +1,161 @@
+package arc
+
+import (
+	"os"
+	"time"
+)
+
+// An Option configures a cache at construction time.
+type Option func(*config)
+
+// config collects the settings applied by Options.
+type config struct {
+	// Directory in which values are stored on disk.
+	dir string
+	// Codec used to compress values written to the on-disk cache directory,
+	// or nil to store them raw.
+	codec Codec
+	// Values shorter than this many bytes are never compressed.
+	minCompressSize int
+	// Whether values are encrypted, and the ID of the key used to do so.
+	encrypt bool
+	keyID   string
+	// Keys able to decrypt values, by key ID.
+	keys map[string][]byte
+	// Permissions for the on-disk cache directory and the files in it.
+	dirMode  os.FileMode
+	fileMode os.FileMode
+	// Bound on the bytes in the on-disk cache directory, or 0 for none.
+	diskQuota   int64
+	quotaPolicy QuotaPolicy
+	// The store the cache sits in front of, or nil if there is none.
+	origin    Origin
+	writeMode WriteMode
+	// How often a SyncARC flushes dirty values, or 0 to flush only on demand.
+	flushInterval time.Duration
+	// Whether an ARC filters new keys, and the rate at which it lets in one seen once.
+	admission       bool
+	admissionFPRate float64
+	// Whether the cache keeps no on-disk cache directory, tracking keys only.
+	noDisk bool
+}
+
+// newConfig returns the default configuration with opts applied in order.
+func newConfig(opts []Option) *config {
+	var conf config
+	conf.dir = "cache_directory"
+	conf.dirMode = 0700
+	conf.fileMode = 0600
+	for _, opt := range opts {
+		opt(&conf)
+	}
+	return &conf
+}
+
+// usesDisk reports whether conf sets any option of the on-disk cache directory.
+func (conf *config) usesDisk() bool {
+	defaults := newConfig(nil)
+	return conf.dir != defaults.dir || conf.codec != nil || conf.encrypt || conf.keys != nil ||
+		conf.dirMode != defaults.dirMode || conf.fileMode != defaults.fileMode || conf.diskQuota != 0
+}
+
+// WithDirectory stores the on-disk cache directory in dir
+// instead of "cache_directory" under the working directory.
+// Caches that are used at the same time need directories of their own.
+func WithDirectory(dir string) Option {
+	return func(conf *config) {
+		conf.dir = dir
+	}
+}
+
+// WithCompression compresses values of at least minSize bytes with codec
+// before writing them to the on-disk cache directory.
+// A value is stored raw if compressing it does not make it smaller.
+// The codec must be registered (see RegisterCodec), or creating the cache fails.
+func WithCompression(codec Codec, minSize int) Option {
+	return func(conf *config) {
+		conf.codec = codec
+		conf.minCompressSize = minSize
+	}
+}
+
+// WithEncryption encrypts values written to the on-disk cache directory
+// with AES-GCM under key, which must be 16, 24 or 32 bytes long.
+// keyID is recorded in each file so that the key can later be rotated.
+// Files that fail authentication are treated as misses.
+func WithEncryption(keyID string, key []byte) Option {
+	return func(conf *config) {
+		conf.encrypt = true
+		conf.keyID = keyID
+		WithDecryptionKey(keyID, key)(conf)
+	}
+}
+
+// WithDecryptionKey makes a retired key available for reading files
+// written before a rotation. New files are always sealed with the
+// key given to WithEncryption.
+func WithDecryptionKey(keyID string, key []byte) Option {
+	return func(conf *config) {
+		if conf.keys == nil {
+			conf.keys = make(map[string][]byte)
+		}
+		conf.keys[keyID] = key
+	}
+}
+
+// WithPermissions sets the modes used to create the on-disk cache directory
+// and the files in it. The default is 0700 and 0600, owner-only.
+// An existing directory loses any permission dirMode does not grant.
+func WithPermissions(dirMode, fileMode os.FileMode) Option {
+	return func(conf *config) {
+		conf.dirMode = dirMode
+		conf.fileMode = fileMode
+	}
+}
+
+// WithDiskQuota bounds the bytes a cache writes to its on-disk cache directory.
+// policy decides what happens to values that do not fit.
+func WithDiskQuota(bytes int64, policy QuotaPolicy) Option {
+	return func(conf *config) {
+		conf.diskQuota = bytes
+		conf.quotaPolicy = policy
+	}
+}
+
+// WithOrigin puts the cache in front of origin: misses are read through from it,
+// removals are deleted from it, and sets reach it according to mode.
+func WithOrigin(origin Origin, mode WriteMode) Option {
+	return func(conf *config) {
+		conf.origin = origin
+		conf.writeMode = mode
+	}
+}
+
+// WithFlushInterval makes a SyncARC in WriteBack mode flush its dirty values
+// every interval, on a goroutine of its own.
+func WithFlushInterval(interval time.Duration) Option {
+	return func(conf *config) {
+		conf.flushInterval = interval
+	}
+}
+
+// withoutDisk makes an ARC keep no on-disk cache directory, so that it only
+// tracks keys, as the "arc" policy does: a ghost hit brings a key back with a nil value.
+func withoutDisk() Option {
+	return func(conf *config) {
+		conf.noDisk = true
+	}
+}
+
+// WithAdmissionFilter makes an ARC turn away keys it has not seen recently:
+// the first Set of a key outside the cache directory is rejected, and only a second
+// one adds it, so keys set once do not churn T1 or the disk.
+// fpRate is the false-positive rate of the filter, the chance that a key seen
+// for the first time is let in anyway; the default, for a rate outside (0, 1), is 0.01.
+// The filter costs about 2*limit*1.44*log2(1/fpRate) bits.
+func WithAdmissionFilter(fpRate float64) Option {
+	return func(conf *config) {
+		conf.admission = true
+		conf.admissionFPRate = fpRate
+	}
+}
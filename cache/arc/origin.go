@@ -0,0 +1,122 @@
+package arc
+
+// An Origin is the slower key-value store that an ARC sits in front of.
+type Origin interface {
+	// Load returns the value stored under key.
+	// ok is false if there is none.
+	Load(key string) (value []byte, ok bool, err error)
+
+	// Store stores value under key.
+	Store(key string, value []byte) error
+
+	// Delete deletes the value stored under key, if there is one.
+	Delete(key string) error
+}
+
+// A WriteMode decides when values set in an ARC reach its origin.
+type WriteMode int
+
+const (
+	// WriteThrough stores each value in the origin before Set returns.
+	WriteThrough WriteMode = iota
+	// WriteBack marks values dirty and stores them in the origin when
+	// they are evicted from T1 or T2, or when the ARC is flushed.
+	WriteBack
+)
+
+// OriginStats reports how an ARC has used its origin.
+type OriginStats struct {
+	// Loads is the number of values read through from the origin on a miss.
+	Loads int
+	// Stores is the number of values written to the origin.
+	Stores int
+	// Deletes is the number of keys deleted from the origin.
+	Deletes int
+	// Errors is the number of origin calls that failed.
+	Errors int
+	// Dirty is the number of cached values not yet stored in the origin.
+	Dirty int
+}
+
+// Flush stores every dirty value in the origin.
+// It returns the first error encountered; values that failed to store stay dirty.
+// A dirty key no longer in the cache has no value left to store, and is skipped,
+// so that its value in the origin is not overwritten with nothing.
+func (arc *ARC) Flush() error {
+	var firstErr error
+	for key := range arc.dirty {
+		value, inCache := arc.CheckCache(key)
+		if !inCache {
+			delete(arc.dirty, key)
+			continue
+		}
+		if err := arc.storeToOrigin(key, value); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		delete(arc.dirty, key)
+	}
+	return firstErr
+}
+
+// OriginStats returns statistics about how this ARC has used its origin.
+func (arc *ARC) OriginStats() *OriginStats {
+	arc.originStats.Dirty = len(arc.dirty)
+	return &arc.originStats
+}
+
+// evictFromCache evicts the least recently used entry of list, which is T1 or T2,
+// first storing its value in the origin if it is dirty.
+func (arc *ARC) evictFromCache(list *LRU) (key string, ok bool) {
+	if key, value, ok := list.Back(); ok && arc.dirty[key] {
+		// A value that cannot be stored is lost, as it would be
+		// if the process exited before a flush.
+		arc.storeToOrigin(key, value)
+		delete(arc.dirty, key)
+	}
+	key, ok = list.Evict()
+	if ok && arc.evicted != nil {
+		arc.evicted(key)
+	}
+	return key, ok
+}
+
+// loadFromOrigin reads key through from the origin on a miss,
+// caching the value with set if it is found.
+func (arc *ARC) loadFromOrigin(key string, set func(key string, value []byte) bool) (value []byte, ok bool) {
+	if arc.origin == nil {
+		return nil, false
+	}
+	value, ok, err := arc.origin.Load(key)
+	if err != nil {
+		arc.originStats.Errors++
+		return nil, false
+	}
+	if ok {
+		arc.originStats.Loads++
+		set(key, value)
+	}
+	return value, ok
+}
+
+// storeToOrigin stores value under key in the origin.
+func (arc *ARC) storeToOrigin(key string, value []byte) error {
+	if err := arc.origin.Store(key, value); err != nil {
+		arc.originStats.Errors++
+		return err
+	}
+	arc.originStats.Stores++
+	return nil
+}
+
+// deleteFromOrigin deletes key from the origin.
+func (arc *ARC) deleteFromOrigin(key string) error {
+	if err := arc.origin.Delete(key); err != nil {
+		arc.originStats.Errors++
+		return err
+	}
+	arc.originStats.Deletes++
+	return nil
+}
@@ -0,0 +1,200 @@
+package arc
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// mapOrigin is an in-memory Origin for testing.
+type mapOrigin struct {
+	mu     sync.Mutex
+	values map[string][]byte
+	fail   bool
+}
+
+func newMapOrigin() *mapOrigin {
+	return &mapOrigin{values: make(map[string][]byte)}
+}
+
+func (origin *mapOrigin) Load(key string) ([]byte, bool, error) {
+	origin.mu.Lock()
+	defer origin.mu.Unlock()
+	value, ok := origin.values[key]
+	return value, ok, nil
+}
+
+func (origin *mapOrigin) Store(key string, value []byte) error {
+	origin.mu.Lock()
+	defer origin.mu.Unlock()
+	if origin.fail {
+		return errors.New("origin unavailable")
+	}
+	origin.values[key] = value
+	return nil
+}
+
+func (origin *mapOrigin) Delete(key string) error {
+	origin.mu.Lock()
+	defer origin.mu.Unlock()
+	delete(origin.values, key)
+	return nil
+}
+
+func (origin *mapOrigin) has(key string) bool {
+	_, ok, _ := origin.Load(key)
+	return ok
+}
+
+// Tests that write-through stores before caching and reads through on a miss
+func TestARC_WriteThrough(t *testing.T) {
+	origin := newMapOrigin()
+	l, err := NewARC(2, WithOrigin(origin, WriteThrough))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Set("a", []byte("1"))
+	if !origin.has("a") {
+		t.Fatalf("write-through did not store")
+	}
+
+	origin.fail = true
+	if l.Set("b", []byte("2")) {
+		t.Fatalf("set should fail when the origin does")
+	}
+	if _, ok := l.CheckCache("b"); ok {
+		t.Fatalf("failed set should not be cached")
+	}
+	origin.fail = false
+
+	origin.Store("c", []byte("3"))
+	if value, ok := l.Get("c"); !ok || string(value) != "3" {
+		t.Fatalf("bad read-through: %q", value)
+	}
+	if _, ok := l.CheckCache("c"); !ok {
+		t.Fatalf("read-through value should be cached")
+	}
+
+	l.Remove("a")
+	if origin.has("a") {
+		t.Fatalf("remove did not delete from the origin")
+	}
+	stats := l.OriginStats()
+	if stats.Stores != 1 || stats.Loads != 1 || stats.Deletes != 1 || stats.Errors != 1 {
+		t.Fatalf("bad origin stats: %+v", *stats)
+	}
+	absolutePath, _ := filepath.Abs("./" + l.cacheDirectory)
+	os.RemoveAll(absolutePath)
+}
+
+// Tests that write-back defers stores until eviction or flush
+func TestARC_WriteBack(t *testing.T) {
+	origin := newMapOrigin()
+	l, err := NewARC(2, WithOrigin(origin, WriteBack))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Set("a", []byte("1"))
+	l.Set("b", []byte("2"))
+	if origin.has("a") || origin.has("b") {
+		t.Fatalf("write-back stored early")
+	}
+	if n := l.OriginStats().Dirty; n != 2 {
+		t.Fatalf("bad dirty count: %d", n)
+	}
+
+	// Evicts "a" from t1
+	l.Set("c", []byte("3"))
+	if !origin.has("a") {
+		t.Fatalf("evicted dirty value was not stored")
+	}
+
+	if err := l.Flush(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !origin.has("b") || !origin.has("c") {
+		t.Fatalf("flush did not store dirty values")
+	}
+	if n := l.OriginStats().Dirty; n != 0 {
+		t.Fatalf("bad dirty count after flush: %d", n)
+	}
+
+	l.Set("b", []byte("4"))
+	l.Remove("b")
+	if origin.has("b") {
+		t.Fatalf("remove did not delete from the origin")
+	}
+	if n := l.OriginStats().Dirty; n != 0 {
+		t.Fatalf("removed value should not be dirty: %d", n)
+	}
+	absolutePath, _ := filepath.Abs("./" + l.cacheDirectory)
+	os.RemoveAll(absolutePath)
+}
+
+// Tests that Flush does not overwrite the origin for a dirty key that left the cache
+func TestARC_FlushSkipsUncached(t *testing.T) {
+	origin := newMapOrigin()
+	l, err := NewARC(2, WithDirectory(t.TempDir()), WithOrigin(origin, WriteBack))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	origin.Store("gone", []byte("real"))
+	// A key marked dirty but no longer in T1 or T2
+	l.dirty["gone"] = true
+	if err := l.Flush(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if value, _, _ := origin.Load("gone"); string(value) != "real" {
+		t.Fatalf("origin overwritten with %q", value)
+	}
+	if n := l.OriginStats().Dirty; n != 0 {
+		t.Fatalf("uncached key left dirty: %d", n)
+	}
+}
+
+// Tests the periodic flush of a SyncARC under concurrent use
+func TestSyncARC_FlushInterval(t *testing.T) {
+	origin := newMapOrigin()
+	l, err := NewSyncARC(64, WithOrigin(origin, WriteBack), WithFlushInterval(time.Millisecond))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				s := fmt.Sprintf("%v-%v", g, i%16)
+				l.Set(s, []byte(s))
+				l.Get(s)
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	deadline := time.Now().Add(time.Second)
+	for l.OriginStats().Dirty > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if n := l.OriginStats().Dirty; n != 0 {
+		t.Fatalf("periodic flush left %d dirty values", n)
+	}
+
+	l.Set("last", []byte("last"))
+	if err := l.Close(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !origin.has("last") {
+		t.Fatalf("close did not flush")
+	}
+	absolutePath, _ := filepath.Abs("./" + l.arc.cacheDirectory)
+	os.RemoveAll(absolutePath)
+}
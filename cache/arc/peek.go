@@ -0,0 +1,108 @@
+package arc
+
+// A Location names the list of an ARC's cache directory a key is in.
+type Location int
+
+const (
+	// T1 holds the keys used once since they were added.
+	T1 Location = iota + 1
+	// T2 holds the keys used more than once.
+	T2
+	// B1 holds the ghosts of keys evicted from T1.
+	B1
+	// B2 holds the ghosts of keys evicted from T2.
+	B2
+)
+
+// String returns the name of the list, such as "T1".
+func (loc Location) String() string {
+	switch loc {
+	case T1:
+		return "T1"
+	case T2:
+		return "T2"
+	case B1:
+		return "B1"
+	case B2:
+		return "B2"
+	}
+	return "none"
+}
+
+// Peek returns the value associated with the given key and the list it is in,
+// if it is in the cache directory. The value of a ghost, in B1 or B2, is nil.
+// This operation DOES NOT count as a "use" for that key, and changes no statistics.
+// ok is true if the key was found and false otherwise.
+func (arc *ARC) Peek(key string) (value []byte, loc Location, ok bool) {
+	for _, loc := range []Location{T1, T2, B1, B2} {
+		if value, found := arc.list(loc).Check(key); found {
+			return value, loc, true
+		}
+	}
+	return nil, 0, false
+}
+
+// Contains reports whether the given key is in the cache, in T1 or T2,
+// without counting a use.
+func (arc *ARC) Contains(key string) bool {
+	return contains(arc.t1List, key) || contains(arc.t2List, key)
+}
+
+// Keys returns the keys in each of T1, T2, B1 and B2,
+// from the most to the least recently used.
+func (arc *ARC) Keys() (t1, t2, b1, b2 []string) {
+	return arc.t1List.Keys(), arc.t2List.Keys(), arc.b1List.Keys(), arc.b2List.Keys()
+}
+
+// Range calls fn for every key in the cache directory, with its value and list,
+// going through T1, T2, B1 and B2 in turn, each from the most to the least
+// recently used key. The value of a ghost is nil. Range stops if fn returns false.
+// No key counts as used, and fn must not modify the ARC.
+func (arc *ARC) Range(fn func(key string, value []byte, loc Location) bool) {
+	for _, loc := range []Location{T1, T2, B1, B2} {
+		more := true
+		arc.list(loc).Range(func(key string, value []byte) bool {
+			more = fn(key, value, loc)
+			return more
+		})
+		if !more {
+			return
+		}
+	}
+}
+
+// list returns the LRU holding the keys of loc.
+func (arc *ARC) list(loc Location) *LRU {
+	switch loc {
+	case T1:
+		return arc.t1List
+	case T2:
+		return arc.t2List
+	case B1:
+		return arc.b1List
+	case B2:
+		return arc.b2List
+	}
+	return nil
+}
+
+// Keys returns the keys in the LRU, from the most to the least recently used.
+func (lru *LRU) Keys() []string {
+	keys := make([]string, 0, lru.nodes.Len())
+	for element := lru.nodes.Front(); element != nil; element = element.Next() {
+		keys = append(keys, element.Value.(string))
+	}
+	return keys
+}
+
+// Range calls fn for every binding in the LRU, from the most to the least
+// recently used, and stops if fn returns false.
+// No binding counts as used, and fn must not modify the LRU.
+func (lru *LRU) Range(fn func(key string, value []byte) bool) {
+	for element := lru.nodes.Front(); element != nil; element = element.Next() {
+		key := element.Value.(string)
+		if !fn(key, lru.cache[key].bytes) {
+			return
+		}
+	}
+}
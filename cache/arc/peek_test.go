@@ -0,0 +1,88 @@
+package arc
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// Tests that Peek reports the list of every key without changing the ARC
+func TestARC_Peek(t *testing.T) {
+	arc := newWorkingSetARC(t)
+	t1, t2, b1, b2 := arc.Keys()
+	stats := *arc.Stats()
+	for key, want := range map[string]Location{"t2-0": T2, "t1-79": T1, "t1-0": B1} {
+		value, loc, ok := arc.Peek(key)
+		if !ok || loc != want {
+			t.Fatalf("%s: got %v, want %v", key, loc, want)
+		}
+		if (loc == B1) != (value == nil) {
+			t.Fatalf("%s: bad value %q", key, value)
+		}
+	}
+	if _, loc, ok := arc.Peek("missing"); ok || loc.String() != "none" {
+		t.Fatalf("missing key found in %v", loc)
+	}
+	if !arc.Contains("t1-79") || arc.Contains("t1-0") {
+		t.Fatalf("bad Contains")
+	}
+	nt1, nt2, nb1, nb2 := arc.Keys()
+	if !reflect.DeepEqual([][]string{t1, t2, b1, b2}, [][]string{nt1, nt2, nb1, nb2}) || *arc.Stats() != stats {
+		t.Fatalf("Peek changed the ARC")
+	}
+}
+
+// Tests that Keys and Range list each list in recency order
+func TestARC_KeysAndRange(t *testing.T) {
+	arc := newWorkingSetARC(t)
+	t1, t2, b1, b2 := arc.Keys()
+	if len(t1) != 60 || len(t2) != 40 || len(b1) != 20 || len(b2) != 0 {
+		t.Fatalf("bad keys: %d %d %d %d", len(t1), len(t2), len(b1), len(b2))
+	}
+	if t1[0] != "t1-79" || t1[59] != "t1-20" || t2[0] != "t2-39" || b1[0] != "t1-19" {
+		t.Fatalf("keys out of order: %s %s %s %s", t1[0], t1[59], t2[0], b1[0])
+	}
+
+	var got []string
+	counts := make(map[Location]int)
+	arc.Range(func(key string, value []byte, loc Location) bool {
+		got = append(got, key)
+		counts[loc]++
+		return true
+	})
+	want := append(append(append([]string{}, t1...), t2...), b1...)
+	if !reflect.DeepEqual(got, want) || counts[T1] != 60 || counts[B1] != 20 {
+		t.Fatalf("bad range: %v", counts)
+	}
+	// Ranging neither promoted nor reordered anything
+	if nt1, _, _, _ := arc.Keys(); !reflect.DeepEqual(nt1, t1) {
+		t.Fatalf("Range changed recency")
+	}
+
+	visited := 0
+	arc.Range(func(key string, value []byte, loc Location) bool {
+		visited++
+		return visited < 5
+	})
+	if visited != 5 {
+		t.Fatalf("Range did not stop: %d", visited)
+	}
+}
+
+// Tests that the keys of an LRU are listed from the most recently used
+func TestLRU_KeysAndRange(t *testing.T) {
+	lru := NewLRU(10)
+	for i := 0; i < 5; i++ {
+		lru.Set(fmt.Sprint(i), []byte(fmt.Sprint(i)))
+	}
+	lru.Get("0")
+	if keys := lru.Keys(); !reflect.DeepEqual(keys, []string{"0", "4", "3", "2", "1"}) {
+		t.Fatalf("bad keys: %v", keys)
+	}
+	lru.Range(func(key string, value []byte) bool {
+		if string(value) != key {
+			t.Fatalf("%s: bad value %q", key, value)
+		}
+		return true
+	})
+}
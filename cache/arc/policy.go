@@ -0,0 +1,181 @@
+package arc
+
+import (
+	"errors"
+	"sort"
+)
+
+// A Policy decides which keys a PolicyCache keeps.
+// The cache stores the values, counts hits and misses, and handles TTLs
+// and the origin; the policy only ever sees keys.
+// A policy may go on remembering keys after evicting them, as ARC's ghost lists do,
+// but only keys it holds resident may be returned by Victim.
+type Policy interface {
+	// OnHit records a use of key, which is resident.
+	OnHit(key string)
+	// OnMiss records a lookup of key, which is not resident.
+	OnMiss(key string)
+	// OnInsert makes key, which is not resident, resident.
+	// The cache then calls Victim for as long as it holds more keys than its limit.
+	OnInsert(key string)
+	// Victim chooses a resident key to evict and stops holding it resident.
+	// ok is false if no key is resident.
+	Victim() (key string, ok bool)
+	// Remove forgets key, whether it is resident or only remembered.
+	Remove(key string)
+}
+
+// policies maps the names of policies to functions returning a new one
+// for a cache with room for limit entries.
+var policies = map[string]func(limit int) Policy{
+	"2q": func(limit int) Policy {
+		return newTwoQueuePolicy(limit, defaultKin, defaultKout)
+	},
+	"lirs": func(limit int) Policy {
+		return newLIRSPolicy(limit, defaultHIRRatio)
+	},
+	"lfu": func(limit int) Policy {
+		return &keyCachePolicy{NewLFU(limit + 1)}
+	},
+	"lfuda": func(limit int) Policy {
+		return &keyCachePolicy{NewLFUDA(limit + 1)}
+	},
+	"lru":      newLRUPolicy,
+	"arc":      newARCPolicy,
+	"car":      newCARPolicy,
+	"clockpro": newClockProPolicy,
+	"s3fifo": func(limit int) Policy {
+		return newS3FIFOPolicy(limit, defaultSmallRatio)
+	},
+	"tinylfu": func(limit int) Policy {
+		return newTinyLFUPolicy(limit, defaultWindowRatio)
+	},
+}
+
+// RegisterPolicy makes a policy available under name
+// to NewPolicyCache, NewPolicy and the simulator.
+func RegisterPolicy(name string, newPolicy func(limit int) Policy) error {
+	if name == "" || newPolicy == nil {
+		return errors.New("arc: policy needs a name and a constructor")
+	}
+	if _, ok := policies[name]; ok {
+		return errors.New("arc: policy " + name + " already registered")
+	}
+	policies[name] = newPolicy
+	return nil
+}
+
+// NewPolicy returns a new instance of the policy registered under name,
+// for a cache with room for limit entries.
+func NewPolicy(name string, limit int) (Policy, error) {
+	newPolicy, ok := policies[name]
+	if !ok {
+		return nil, errors.New("arc: unknown policy " + name)
+	}
+	if limit <= 0 {
+		return nil, errors.New("Capacity must be greater than zero")
+	}
+	return newPolicy(limit), nil
+}
+
+// PolicyNames returns the names of the registered policies in sorted order.
+func PolicyNames() []string {
+	names := make([]string, 0, len(policies))
+	for name := range policies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// contains reports whether key is in list, without counting a use.
+func contains(list *LRU, key string) bool {
+	_, ok := list.Check(key)
+	return ok
+}
+
+// lruPolicy evicts the least recently used key.
+type lruPolicy struct {
+	keys *LRU
+}
+
+func newLRUPolicy(limit int) Policy {
+	var policy lruPolicy
+	// One spare entry holds the key being inserted until the cache asks for a victim.
+	policy.keys = NewLRU(limit + 1)
+	return &policy
+}
+
+func (policy *lruPolicy) OnHit(key string) {
+	policy.keys.Set(key, nil)
+}
+
+func (policy *lruPolicy) OnMiss(key string) {}
+
+func (policy *lruPolicy) OnInsert(key string) {
+	policy.keys.Set(key, nil)
+}
+
+func (policy *lruPolicy) Victim() (key string, ok bool) {
+	return policy.keys.Evict()
+}
+
+func (policy *lruPolicy) Remove(key string) {
+	policy.keys.Remove(key)
+}
+
+// arcPolicy is the replacement policy of ARC, run by an ARC that only tracks keys:
+// it has no on-disk cache directory, so a ghost hit brings the key back with a nil
+// value, and the cache that holds the values is told which keys the ARC evicts.
+type arcPolicy struct {
+	arc *ARC
+	// Keys the ARC evicted, waiting to be returned by Victim.
+	victims []string
+}
+
+func newARCPolicy(limit int) Policy {
+	var policy arcPolicy
+	// The limit has been checked by NewPolicy, and there is no directory to create.
+	policy.arc, _ = NewARC(limit, withoutDisk())
+	policy.arc.evicted = func(key string) {
+		policy.victims = append(policy.victims, key)
+	}
+	return &policy
+}
+
+func (policy *arcPolicy) OnHit(key string) {
+	policy.arc.Access(key)
+}
+
+func (policy *arcPolicy) OnMiss(key string) {}
+
+func (policy *arcPolicy) OnInsert(key string) {
+	policy.arc.set(key, nil)
+}
+
+func (policy *arcPolicy) Victim() (key string, ok bool) {
+	for {
+		for len(policy.victims) > 0 {
+			key = policy.victims[0]
+			policy.victims = policy.victims[1:]
+			// A victim brought back since, by a ghost hit, is resident again.
+			if !policy.arc.Contains(key) {
+				return key, true
+			}
+		}
+		if policy.arc.Len() == 0 {
+			return "", false
+		}
+		policy.arc.Evict("")
+	}
+}
+
+func (policy *arcPolicy) Remove(key string) {
+	policy.arc.drop(key)
+	for i := 0; i < len(policy.victims); i++ {
+		if policy.victims[i] == key {
+			policy.victims = append(policy.victims[:i], policy.victims[i+1:]...)
+			i--
+		}
+	}
+}
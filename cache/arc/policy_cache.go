@@ -0,0 +1,279 @@
+package arc
+
+import (
+	"errors"
+	"time"
+)
+
+// A PolicyCache is a fixed-size cache whose replacement decisions are made by a Policy.
+// It keeps the values in memory only, with the same TTLs and origin support as an ARC,
+// so policies can be swapped without changing anything else. An evicted value is
+// read through from the origin again, if there is one. It has no on-disk cache
+// directory, and options for one are refused.
+type PolicyCache struct {
+	name   string
+	policy Policy
+	// Values of the resident keys.
+	values map[string][]byte
+	// The store this cache sits in front of, or nil if there is none.
+	origin    Origin
+	writeMode WriteMode
+	// Resident keys whose values have not been stored in the origin yet.
+	dirty       map[string]bool
+	originStats OriginStats
+	// Deadlines of keys set with a TTL.
+	expiry map[string]time.Time
+	// The maximum number of resident keys.
+	limit int
+	stats Stats
+}
+
+var _ Cache = (*PolicyCache)(nil)
+
+// NewPolicyCache returns a cache with room for limit entries,
+// managed by the policy registered under name, such as "lru" or "arc".
+func NewPolicyCache(name string, limit int, opts ...Option) (*PolicyCache, error) {
+	policy, err := NewPolicy(name, limit)
+	if err != nil {
+		return nil, err
+	}
+	return newPolicyCache(name, policy, limit, opts)
+}
+
+// newPolicyCache returns a cache with room for limit entries managed by policy.
+func newPolicyCache(name string, policy Policy, limit int, opts []Option) (*PolicyCache, error) {
+	if limit <= 0 {
+		return nil, errors.New("Capacity must be greater than zero")
+	}
+	conf := newConfig(opts)
+	if conf.usesDisk() {
+		return nil, errors.New("arc: a PolicyCache has no on-disk cache directory")
+	}
+	var cache PolicyCache
+	cache.name = name
+	cache.policy = policy
+	cache.values = make(map[string][]byte)
+	cache.origin = conf.origin
+	cache.writeMode = conf.writeMode
+	cache.dirty = make(map[string]bool)
+	cache.expiry = make(map[string]time.Time)
+	cache.limit = limit
+	return &cache, nil
+}
+
+// Policy returns the name of the cache's policy.
+func (cache *PolicyCache) Policy() string {
+	return cache.name
+}
+
+// MaxStorage returns the maximum number of entries the cache can store.
+func (cache *PolicyCache) MaxStorage() int {
+	return cache.limit
+}
+
+// RemainingStorage returns the number of unused spaces for entries in the cache.
+func (cache *PolicyCache) RemainingStorage() int {
+	return cache.limit - len(cache.values)
+}
+
+// Get returns the value associated with the given key, if it exists.
+// This operation counts as a "use" for that key-value pair.
+// On a miss, the value is read through from the origin, if there is one.
+func (cache *PolicyCache) Get(key string) (value []byte, ok bool) {
+	cache.dropIfExpired(key)
+	if value, ok := cache.values[key]; ok {
+		cache.stats.Hits++
+		cache.policy.OnHit(key)
+		return value, true
+	}
+	cache.stats.Misses++
+	cache.policy.OnMiss(key)
+	return cache.loadFromOrigin(key)
+}
+
+// Check returns the value associated with the given key, if it exists,
+// without counting a use.
+func (cache *PolicyCache) Check(key string) (value []byte, ok bool) {
+	cache.dropIfExpired(key)
+	value, ok = cache.values[key]
+	return value, ok
+}
+
+// Remove removes and returns the value associated with the given key, if it exists,
+// and deletes it from the origin, if there is one, whether or not it was cached.
+func (cache *PolicyCache) Remove(key string) (value []byte, ok bool) {
+	if cache.origin != nil {
+		delete(cache.dirty, key)
+		cache.deleteFromOrigin(key)
+	}
+	cache.dropIfExpired(key)
+	value, ok = cache.values[key]
+	cache.policy.Remove(key)
+	if ok {
+		cache.forget(key)
+	}
+	return value, ok
+}
+
+// Set associates the given value with the given key, possibly evicting values
+// to make room. Returns true if the binding was added successfully, else false.
+// Origins are written to as by ARC.Set.
+func (cache *PolicyCache) Set(key string, value []byte) (ok bool) {
+	cache.dropIfExpired(key)
+	if cache.origin != nil {
+		if cache.writeMode == WriteThrough {
+			if err := cache.storeToOrigin(key, value); err != nil {
+				return false
+			}
+		} else {
+			cache.dirty[key] = true
+		}
+	}
+	delete(cache.expiry, key)
+	return cache.set(key, value)
+}
+
+// SetWithTTL is like Set, but the binding expires after ttl.
+// A ttl of zero or less sets a binding that never expires.
+func (cache *PolicyCache) SetWithTTL(key string, value []byte, ttl time.Duration) (ok bool) {
+	ok = cache.Set(key, value)
+	if ok && ttl > 0 {
+		cache.expiry[key] = time.Now().Add(ttl)
+	}
+	return ok
+}
+
+// TTL returns the time left until the binding for key expires.
+// hasTTL is false if the binding never expires, and ok is false if key is not in the cache.
+func (cache *PolicyCache) TTL(key string) (ttl time.Duration, hasTTL bool, ok bool) {
+	if _, ok := cache.Check(key); !ok {
+		return 0, false, false
+	}
+	deadline, hasTTL := cache.expiry[key]
+	if !hasTTL {
+		return 0, false, true
+	}
+	return time.Until(deadline), true, true
+}
+
+// set is Set without writing to the origin.
+// It reports false if the policy chose the new key itself as the victim.
+func (cache *PolicyCache) set(key string, value []byte) (ok bool) {
+	if _, resident := cache.values[key]; resident {
+		cache.policy.OnHit(key)
+	} else {
+		cache.policy.OnInsert(key)
+	}
+	cache.values[key] = value
+	for len(cache.values) > cache.limit {
+		victim, ok := cache.policy.Victim()
+		if !ok {
+			break
+		}
+		cache.evict(victim)
+	}
+	_, ok = cache.values[key]
+	return ok
+}
+
+// evict drops a victim chosen by the policy,
+// first storing its value in the origin if it is dirty.
+func (cache *PolicyCache) evict(key string) {
+	if cache.dirty[key] {
+		// A value that cannot be stored is lost, as with ARC.
+		cache.storeToOrigin(key, cache.values[key])
+		delete(cache.dirty, key)
+	}
+	cache.forget(key)
+}
+
+// forget drops the value of a key that is no longer resident, and all else kept about it.
+func (cache *PolicyCache) forget(key string) {
+	delete(cache.values, key)
+	delete(cache.expiry, key)
+}
+
+// dropIfExpired drops the binding for key if its deadline has passed.
+// A dirty value is stored in the origin first, since it is the latest write.
+func (cache *PolicyCache) dropIfExpired(key string) {
+	deadline, found := cache.expiry[key]
+	if !found || time.Now().Before(deadline) {
+		return
+	}
+	if cache.dirty[key] {
+		cache.storeToOrigin(key, cache.values[key])
+		delete(cache.dirty, key)
+	}
+	cache.policy.Remove(key)
+	cache.forget(key)
+}
+
+// Flush stores every dirty value in the origin.
+// It returns the first error encountered; values that failed to store stay dirty.
+func (cache *PolicyCache) Flush() error {
+	var firstErr error
+	for key := range cache.dirty {
+		if err := cache.storeToOrigin(key, cache.values[key]); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		delete(cache.dirty, key)
+	}
+	return firstErr
+}
+
+// loadFromOrigin reads key through from the origin on a miss,
+// caching the value if it is found.
+func (cache *PolicyCache) loadFromOrigin(key string) (value []byte, ok bool) {
+	if cache.origin == nil {
+		return nil, false
+	}
+	value, ok, err := cache.origin.Load(key)
+	if err != nil {
+		cache.originStats.Errors++
+		return nil, false
+	}
+	if ok {
+		cache.originStats.Loads++
+		cache.set(key, value)
+	}
+	return value, ok
+}
+
+// storeToOrigin stores value under key in the origin.
+func (cache *PolicyCache) storeToOrigin(key string, value []byte) error {
+	if err := cache.origin.Store(key, value); err != nil {
+		cache.originStats.Errors++
+		return err
+	}
+	cache.originStats.Stores++
+	return nil
+}
+
+// deleteFromOrigin deletes key from the origin.
+func (cache *PolicyCache) deleteFromOrigin(key string) error {
+	if err := cache.origin.Delete(key); err != nil {
+		cache.originStats.Errors++
+		return err
+	}
+	cache.originStats.Deletes++
+	return nil
+}
+
+// Len returns the number of bindings in the cache.
+func (cache *PolicyCache) Len() int {
+	return len(cache.values)
+}
+
+// Stats returns statistics about how many search hits and misses have occurred.
+func (cache *PolicyCache) Stats() *Stats {
+	return &cache.stats
+}
+
+// OriginStats returns statistics about how this cache has used its origin.
+func (cache *PolicyCache) OriginStats() *OriginStats {
+	cache.originStats.Dirty = len(cache.dirty)
+	return &cache.originStats
+}
@@ -0,0 +1,197 @@
+package arc
+
+import (
+	"compress/gzip"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+// Tests every registered policy through a PolicyCache
+func TestPolicyCache_Policies(t *testing.T) {
+	for _, name := range PolicyNames() {
+		t.Run(name, func(t *testing.T) {
+			cache, err := NewPolicyCache(name, 16)
+			if err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			if cache.Policy() != name {
+				t.Fatalf("bad policy name: %q", cache.Policy())
+			}
+			for i := 0; i < 200; i++ {
+				key := fmt.Sprint(i % 40)
+				if value, ok := cache.Get(key); ok && string(value) != key {
+					t.Fatalf("bad value for %s: %q", key, value)
+				}
+				cache.Set(key, []byte(key))
+				if cache.Len() > 16 {
+					t.Fatalf("len %d over limit", cache.Len())
+				}
+			}
+			if cache.RemainingStorage() != 16-cache.Len() {
+				t.Fatalf("bad remaining storage: %d", cache.RemainingStorage())
+			}
+			stats := cache.Stats()
+			if stats.Hits+stats.Misses != 200 {
+				t.Fatalf("bad stats: %+v", stats)
+			}
+
+			cache.Set("a", []byte("1"))
+			if value, ok := cache.Check("a"); !ok || string(value) != "1" {
+				t.Fatalf("bad value: %q", value)
+			}
+			if value, ok := cache.Remove("a"); !ok || string(value) != "1" {
+				t.Fatalf("bad remove: %q", value)
+			}
+			if _, ok := cache.Get("a"); ok {
+				t.Fatalf("removed key found")
+			}
+			if _, ok := cache.Remove("a"); ok {
+				t.Fatalf("removed key removed again")
+			}
+		})
+	}
+}
+
+// Tests TTLs and read-through in a PolicyCache
+func TestPolicyCache_TTLAndOrigin(t *testing.T) {
+	origin := newMapOrigin()
+	origin.values["cold"] = []byte("from origin")
+	cache, err := NewPolicyCache("lru", 4, WithOrigin(origin, WriteBack))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if value, ok := cache.Get("cold"); !ok || string(value) != "from origin" {
+		t.Fatalf("no read-through: %q", value)
+	}
+	cache.SetWithTTL("short", []byte("1"), time.Millisecond)
+	if _, hasTTL, ok := cache.TTL("short"); !ok || !hasTTL {
+		t.Fatalf("ttl not set")
+	}
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := cache.Check("short"); ok {
+		t.Fatalf("short should have expired")
+	}
+	if !origin.has("short") {
+		t.Fatalf("expired dirty value not stored")
+	}
+
+	for i := 0; i < 5; i++ {
+		cache.Set(fmt.Sprint(i), []byte("v"))
+	}
+	if !origin.has("0") || origin.has("4") {
+		t.Fatalf("write-back stored the wrong values")
+	}
+	if err := cache.Flush(); err != nil || !origin.has("4") {
+		t.Fatalf("flush failed: %v", err)
+	}
+}
+
+// Tests the registry
+func TestPolicy_Registry(t *testing.T) {
+	if _, err := NewPolicyCache("nope", 4); err == nil {
+		t.Fatalf("unknown policy accepted")
+	}
+	if _, err := NewPolicy("lru", 0); err == nil {
+		t.Fatalf("zero limit accepted")
+	}
+	if err := RegisterPolicy("lru", newLRUPolicy); err == nil {
+		t.Fatalf("duplicate policy registered")
+	}
+}
+
+// Tests that a PolicyCache refuses the options of an on-disk cache directory it does not have
+func TestPolicyCache_DiskOptions(t *testing.T) {
+	dir := t.TempDir()
+	for _, opt := range []Option{
+		WithDirectory(dir),
+		WithCompression(NewGzipCodec(gzip.DefaultCompression), 0),
+		WithEncryption("k1", make([]byte, 32)),
+		WithPermissions(0755, 0644),
+		WithDiskQuota(1024, QuotaSkip),
+	} {
+		if _, err := NewPolicyCache("lru", 4, opt); err == nil {
+			t.Fatalf("disk option accepted")
+		}
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 0 {
+		t.Fatalf("%d files written", len(files))
+	}
+	if _, err := NewTwoQueue(4, 0.25, 0.5, WithOrigin(newMapOrigin(), WriteThrough)); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+// Tests the simulator on traces whose outcome is known
+func TestSimulate(t *testing.T) {
+	// A loop one larger than the cache defeats LRU entirely
+	loop, _ := NewTrace("loop", 10000, 101, 1)
+	if stats, _ := Simulate("lru", 100, loop); stats.Hits != 0 {
+		t.Fatalf("lru hit on a loop: %+v", stats)
+	}
+
+	// ARC keeps the working set through scans that flush LRU
+	scan, _ := NewTrace("scan", 200000, 10000, 1)
+	lru, _ := Simulate("lru", 500, scan)
+	arc, _ := Simulate("arc", 500, scan)
+	t.Logf("scan: lru %.4f arc %.4f", lru.HitRatio(), arc.HitRatio())
+	if arc.HitRatio() <= lru.HitRatio() {
+		t.Fatalf("arc %.4f no better than lru %.4f on scans", arc.HitRatio(), lru.HitRatio())
+	}
+
+	zipf, _ := NewTrace("zipf", 200000, 10000, 1)
+	for _, name := range PolicyNames() {
+		stats, err := Simulate(name, 500, zipf)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		t.Logf("zipf: %s %.4f", name, stats.HitRatio())
+		if stats.Hits+stats.Misses != len(zipf) || stats.HitRatio() < 0.3 {
+			t.Fatalf("%s: bad stats %+v", name, stats)
+		}
+	}
+}
+
+// Tests that the "arc" policy makes the same decisions as an ARC
+func TestARCPolicy_MatchesARC(t *testing.T) {
+	trace, _ := NewTrace("scan", 5000, 1000, 1)
+	arc, err := NewARC(100, WithDirectory(t.TempDir()))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	hits := 0
+	for _, key := range trace {
+		if _, ok := arc.Get(key); ok {
+			hits++
+		} else {
+			arc.Set(key, []byte("v"))
+		}
+	}
+	stats, _ := Simulate("arc", 100, trace)
+	if stats.Hits != hits {
+		t.Fatalf("arc policy hit %d times, ARC %d", stats.Hits, hits)
+	}
+}
+
+// Tests that the "arc" policy never returns a victim that was removed or brought back since
+func TestARCPolicy_StaleVictims(t *testing.T) {
+	policy, _ := NewPolicy("arc", 2)
+	policy.OnInsert("a")
+	policy.OnInsert("b")
+	// a is evicted into B1, then brought back by a ghost hit, which evicts b
+	policy.OnInsert("c")
+	policy.OnInsert("a")
+	if key, ok := policy.Victim(); !ok || key != "b" {
+		t.Fatalf("bad victim: %q", key)
+	}
+	policy.OnInsert("d")
+	policy.Remove("a")
+	policy.Remove("c")
+	if key, ok := policy.Victim(); !ok || key != "d" {
+		t.Fatalf("bad victim after removals: %q", key)
+	}
+	if key, ok := policy.Victim(); ok {
+		t.Fatalf("victim %q from an empty policy", key)
+	}
+}
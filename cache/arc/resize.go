@@ -0,0 +1,55 @@
+package arc
+
+import "errors"
+
+// Resize changes the maximum number of entries the ARC can store to newLimit.
+// The target marker is scaled by the same factor, so T1 keeps its share of the cache.
+// Shrinking evicts entries as Set would, demoting them to the ghost lists,
+// then drops the least recently used ghosts, and their files, until the lists
+// are within the new bounds. Growing only raises the bounds; nothing is evicted.
+// Dirty values evicted are stored in the origin, as on any eviction.
+// The hit curve starts over, as it was estimated for the old capacity.
+func (arc *ARC) Resize(newLimit int) error {
+	if newLimit <= 0 {
+		return errors.New("Capacity must be greater than zero")
+	}
+	arc.targetMarker = min(newLimit, arc.targetMarker*newLimit/arc.limit)
+	// As the target marker is at most newLimit, each eviction takes from T1
+	// whenever T2 is empty, so the loop ends.
+	for arc.Len() > newLimit {
+		arc.Evict("")
+	}
+	arc.limit = newLimit
+	for arc.t1List.Len()+arc.b1List.Len() > newLimit && arc.b1List.Len() > 0 {
+		evictedKey, _ := arc.b1List.Evict()
+		arc.forget(evictedKey)
+	}
+	for arc.b1List.Len()+arc.b2List.Len() > newLimit {
+		ghosts := arc.b2List
+		if ghosts.Len() == 0 {
+			ghosts = arc.b1List
+		}
+		evictedKey, _ := ghosts.Evict()
+		arc.forget(evictedKey)
+	}
+	for _, list := range []*LRU{arc.t1List, arc.t2List, arc.b1List, arc.b2List} {
+		list.Resize(newLimit)
+	}
+	arc.resetCurve()
+	return nil
+}
+
+// Resize changes the maximum number of entries the LRU can store to limit,
+// evicting the least recently used bindings that no longer fit.
+// It returns the number of bindings evicted.
+func (lru *LRU) Resize(limit int) (evicted int, err error) {
+	if limit <= 0 {
+		return 0, errors.New("Capacity must be greater than zero")
+	}
+	lru.limit = limit
+	for lru.usedEntries > limit {
+		lru.Evict()
+		evicted++
+	}
+	return evicted, nil
+}
@@ -0,0 +1,137 @@
+package arc
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+// Checks the entry bounds of an ARC's lists, and that only the cache directory is on disk
+func checkBounds(t *testing.T, arc *ARC) {
+	t.Helper()
+	t1, t2, b1, b2 := arc.ListLens()
+	limit := arc.MaxEntries()
+	if t1+t2 > limit || b1+b2 > limit || t1+b1 > limit || t1+t2+b1+b2 > 2*limit {
+		t.Fatalf("bounds broken: t1 %d t2 %d b1 %d b2 %d limit %d", t1, t2, b1, b2, limit)
+	}
+	if arc.TargetMarker() < 0 || arc.TargetMarker() > limit {
+		t.Fatalf("bad target marker: %d", arc.TargetMarker())
+	}
+	files, err := os.ReadDir(arc.cacheDirectory)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(files) != t1+t2+b1+b2 {
+		t.Fatalf("%d files for %d keys", len(files), t1+t2+b1+b2)
+	}
+}
+
+// Tests that shrinking an ARC evicts through its lists and cleans up the disk
+func TestARC_ResizeShrink(t *testing.T) {
+	arc := newWorkingSetARC(t)
+	// Hits on the ghosts of T1 raise the target marker
+	for i := 0; i < 10; i++ {
+		arc.Get(fmt.Sprint("t1-", i))
+	}
+	target := arc.TargetMarker()
+	if target == 0 {
+		t.Fatalf("target marker did not move")
+	}
+	if err := arc.Resize(50); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if arc.MaxEntries() != 50 || arc.Len() != 50 || arc.TargetMarker() != target/2 {
+		t.Fatalf("bad resize: limit %d, %d entries, target %d", arc.MaxEntries(), arc.Len(), arc.TargetMarker())
+	}
+	checkBounds(t, arc)
+	// T2 keeps the keys used most recently
+	if _, loc, _ := arc.Peek("t1-9"); loc != T2 {
+		t.Fatalf("t1-9 in %v", loc)
+	}
+	// The cache works within its new bounds
+	for i := 0; i < 200; i++ {
+		arc.Set(fmt.Sprint("new", i), []byte("v"))
+		arc.Get(fmt.Sprint("new", i/2))
+		checkBounds(t, arc)
+	}
+	if err := arc.Resize(0); err == nil {
+		t.Fatalf("zero capacity accepted")
+	}
+}
+
+// Tests that growing an ARC keeps its entries and makes room for more
+func TestARC_ResizeGrow(t *testing.T) {
+	arc := newWorkingSetARC(t)
+	t1, t2, b1, b2 := arc.ListLens()
+	if err := arc.Resize(300); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if nt1, nt2, nb1, nb2 := arc.ListLens(); nt1 != t1 || nt2 != t2 || nb1 != b1 || nb2 != b2 {
+		t.Fatalf("growing changed the lists: %d %d %d %d", nt1, nt2, nb1, nb2)
+	}
+	// L1 can now hold 300 keys, so 140 more fit without evicting anything
+	for i := 0; i < 140; i++ {
+		arc.Set(fmt.Sprint("new", i), []byte("v"))
+	}
+	if arc.Len() != 240 || arc.RemainingSpaces() != 60 {
+		t.Fatalf("bad size after growing: %d", arc.Len())
+	}
+	if _, _, b1, _ := arc.ListLens(); b1 != 20 {
+		t.Fatalf("ghosts evicted: %d left", b1)
+	}
+	checkBounds(t, arc)
+}
+
+// Tests that dirty values evicted by shrinking reach the origin
+func TestARC_ResizeWriteBack(t *testing.T) {
+	origin := newMapOrigin()
+	arc, err := NewARC(10, WithDirectory(t.TempDir()), WithOrigin(origin, WriteBack))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		arc.Set(fmt.Sprint(i), []byte("v"))
+	}
+	arc.Resize(4)
+	for i := 0; i < 6; i++ {
+		if !origin.has(fmt.Sprint(i)) {
+			t.Fatalf("evicted value %d not stored", i)
+		}
+	}
+	if arc.OriginStats().Dirty != 4 {
+		t.Fatalf("bad stats: %+v", arc.OriginStats())
+	}
+}
+
+// Tests that resizing an LRU evicts the least recently used bindings
+func TestLRU_Resize(t *testing.T) {
+	lru := NewLRU(5)
+	for i := 0; i < 5; i++ {
+		lru.Set(fmt.Sprint(i), nil)
+	}
+	if evicted, err := lru.Resize(2); err != nil || evicted != 3 || lru.Len() != 2 || lru.RemainingSpaces() != 0 {
+		t.Fatalf("bad shrink: %d evicted, err: %v", evicted, err)
+	}
+	if _, ok := lru.Check("4"); !ok {
+		t.Fatalf("most recent key evicted")
+	}
+	lru.Resize(4)
+	lru.Set("a", nil)
+	lru.Set("b", nil)
+	if lru.Len() != 4 {
+		t.Fatalf("bad grow: %d", lru.Len())
+	}
+
+	for _, limit := range []int{0, -1} {
+		if _, err := lru.Resize(limit); err == nil {
+			t.Fatalf("resize to %d accepted", limit)
+		}
+	}
+	if lru.MaxEntries() != 4 || lru.Len() != 4 {
+		t.Fatalf("rejected resize changed the LRU: limit %d, %d entries", lru.MaxEntries(), lru.Len())
+	}
+	lru.Set("c", nil)
+	if lru.Len() != 4 {
+		t.Fatalf("bad set after rejected resize: %d", lru.Len())
+	}
+}
@@ -0,0 +1,440 @@
+package arc
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Limits on requests accepted by RESPServer.
+const (
+	maxRESPArgs    = 1 << 20
+	maxRESPBulkLen = 1 << 26
+)
+
+// errRESPProtocol is returned when a client sends a malformed request.
+var errRESPProtocol = errors.New("Protocol error")
+
+// A RESPServer serves a SyncARC over the Redis serialization protocol,
+// so that Redis clients can use ARC eviction. Connections speak RESP2
+// until they switch to RESP3 with HELLO 3.
+// Each connection is served by a goroutine of its own.
+type RESPServer struct {
+	connServer
+	cache    *SyncARC
+	started  time.Time
+	commands int64
+}
+
+// NewRESPServer returns a RESPServer serving cache.
+func NewRESPServer(cache *SyncARC) *RESPServer {
+	var server RESPServer
+	server.connServer.init(server.handleConn)
+	server.cache = cache
+	server.started = time.Now()
+	return &server
+}
+
+// A respConn reads requests from and writes replies to one client.
+type respConn struct {
+	reader *bufio.Reader
+	writer *bufio.Writer
+	// proto is the RESP version negotiated with HELLO, 2 or 3.
+	proto int
+}
+
+// handleConn reads and answers commands on conn until it is closed or the client quits.
+func (server *RESPServer) handleConn(conn net.Conn) {
+	client := &respConn{bufio.NewReader(conn), bufio.NewWriter(conn), 2}
+	for {
+		args, err := client.readCommand()
+		if err == errRESPProtocol {
+			client.writeError("ERR Protocol error")
+			client.writer.Flush()
+			return
+		}
+		if err != nil {
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+		quit := server.dispatch(client, args)
+		if err := client.writer.Flush(); err != nil || quit {
+			return
+		}
+	}
+}
+
+// dispatch runs the command in args and writes its reply.
+// It reports whether the connection should close.
+func (server *RESPServer) dispatch(client *respConn, args [][]byte) (quit bool) {
+	atomic.AddInt64(&server.commands, 1)
+	name := strings.ToUpper(string(args[0]))
+	arity, known := respArity[name]
+	if !known {
+		client.writeError(fmt.Sprintf("ERR unknown command '%s'", args[0]))
+		return false
+	}
+	if (arity > 0 && len(args) != arity) || (arity < 0 && len(args) < -arity) {
+		client.writeError(fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(name)))
+		return false
+	}
+
+	switch name {
+	case "PING":
+		if len(args) > 1 {
+			client.writeBulk(args[1])
+		} else {
+			client.writeSimple("PONG")
+		}
+	case "HELLO":
+		server.hello(client, args)
+	case "QUIT":
+		client.writeSimple("OK")
+		return true
+	case "COMMAND":
+		client.writeArrayLen(0)
+	case "SELECT":
+		if string(args[1]) != "0" {
+			client.writeError("ERR DB index is out of range")
+		} else {
+			client.writeSimple("OK")
+		}
+	case "GET":
+		if value, ok := server.cache.Get(string(args[1])); ok {
+			client.writeBulk(value)
+		} else {
+			client.writeNull()
+		}
+	case "SET":
+		server.set(client, args)
+	case "DEL":
+		var removed int64
+		server.cache.Do(func(arc *ARC) {
+			for _, key := range args[1:] {
+				arc.dropIfExpired(string(key))
+				// Remove also drops a ghost, but only keys EXISTS finds are counted.
+				if _, ok := arc.CheckCache(string(key)); ok {
+					removed++
+				}
+				arc.Remove(string(key))
+			}
+		})
+		client.writeInt(removed)
+	case "EXISTS":
+		var found int64
+		for _, key := range args[1:] {
+			if _, ok := server.cache.CheckCache(string(key)); ok {
+				found++
+			}
+		}
+		client.writeInt(found)
+	case "EXPIRE", "PEXPIRE":
+		n, err := strconv.ParseInt(string(args[2]), 10, 64)
+		if err != nil {
+			client.writeError("ERR value is not an integer or out of range")
+			return false
+		}
+		unit := time.Millisecond
+		if name == "EXPIRE" {
+			unit = time.Second
+		}
+		ttl, ok := expireTime(n, unit)
+		if !ok {
+			client.writeError(fmt.Sprintf("ERR invalid expire time in '%s' command", strings.ToLower(name)))
+			return false
+		}
+		// A TTL that is not positive deletes the key, as in Redis.
+		if ttl <= 0 {
+			ttl = -1
+		}
+		if server.cache.Expire(string(args[1]), ttl) {
+			client.writeInt(1)
+		} else {
+			client.writeInt(0)
+		}
+	case "TTL", "PTTL":
+		ttl, hasTTL, ok := server.cache.TTL(string(args[1]))
+		switch {
+		case !ok:
+			client.writeInt(-2)
+		case !hasTTL:
+			client.writeInt(-1)
+		case name == "TTL":
+			client.writeInt(int64((ttl + time.Second/2) / time.Second))
+		default:
+			client.writeInt(int64(ttl / time.Millisecond))
+		}
+	case "DBSIZE":
+		client.writeInt(int64(server.cache.Len()))
+	case "FLUSHDB", "FLUSHALL":
+		server.cache.Purge()
+		client.writeSimple("OK")
+	case "INFO":
+		section := "default"
+		if len(args) > 1 {
+			section = strings.ToLower(string(args[1]))
+		}
+		client.writeBulk([]byte(server.info(section)))
+	}
+	return false
+}
+
+// respArity gives the number of arguments of each command, including its name.
+// A negative arity -n means at least n arguments.
+var respArity = map[string]int{
+	"PING": -1, "HELLO": -1, "QUIT": 1, "COMMAND": -1, "SELECT": 2,
+	"GET": 2, "SET": -3, "DEL": -2, "EXISTS": -2,
+	"EXPIRE": 3, "PEXPIRE": 3, "TTL": 2, "PTTL": 2,
+	"DBSIZE": 1, "FLUSHDB": -1, "FLUSHALL": -1, "INFO": -1,
+}
+
+// hello answers "HELLO [protover]", switching the connection's protocol version.
+func (server *RESPServer) hello(client *respConn, args [][]byte) {
+	if len(args) > 1 {
+		proto, err := strconv.Atoi(string(args[1]))
+		if err != nil || (proto != 2 && proto != 3) {
+			client.writeError("NOPROTO unsupported protocol version")
+			return
+		}
+		client.proto = proto
+	}
+	client.writeMapLen(5)
+	client.writeBulk([]byte("server"))
+	client.writeBulk([]byte("arc"))
+	client.writeBulk([]byte("version"))
+	client.writeBulk([]byte("1.0.0"))
+	client.writeBulk([]byte("proto"))
+	client.writeInt(int64(client.proto))
+	client.writeBulk([]byte("mode"))
+	client.writeBulk([]byte("standalone"))
+	client.writeBulk([]byte("role"))
+	client.writeBulk([]byte("master"))
+}
+
+// set answers "SET key value [NX|XX] [GET] [EX seconds|PX milliseconds|KEEPTTL]".
+func (server *RESPServer) set(client *respConn, args [][]byte) {
+	key := string(args[1])
+	value := args[2]
+	var nx, xx, get, keepTTL bool
+	var ttl time.Duration
+	for i := 3; i < len(args); i++ {
+		switch strings.ToUpper(string(args[i])) {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "GET":
+			get = true
+		case "KEEPTTL":
+			keepTTL = true
+		case "EX", "PX":
+			if i+1 == len(args) || ttl != 0 {
+				client.writeError("ERR syntax error")
+				return
+			}
+			unit := time.Millisecond
+			if strings.ToUpper(string(args[i])) == "EX" {
+				unit = time.Second
+			}
+			n, err := strconv.ParseInt(string(args[i+1]), 10, 64)
+			if err == nil && n > 0 {
+				ttl, _ = expireTime(n, unit)
+			}
+			if ttl <= 0 {
+				client.writeError("ERR invalid expire time in 'set' command")
+				return
+			}
+			i++
+		default:
+			client.writeError("ERR syntax error")
+			return
+		}
+	}
+	if (nx && xx) || (keepTTL && ttl != 0) {
+		client.writeError("ERR syntax error")
+		return
+	}
+
+	var old []byte
+	var exists, stored, failed bool
+	server.cache.Do(func(arc *ARC) {
+		arc.dropIfExpired(key)
+		old, exists = arc.CheckCache(key)
+		if (nx && exists) || (xx && !exists) {
+			return
+		}
+		if keepTTL {
+			ttl, _, _ = arc.TTL(key)
+		}
+		err := arc.TrySet(key, value, ttl)
+		stored = err == nil
+		failed = err != nil && err != ErrNotAdmitted
+	})
+	switch {
+	case failed:
+		client.writeError("ERR unable to store value")
+	case get && exists:
+		client.writeBulk(old)
+	case get || !stored:
+		client.writeNull()
+	default:
+		client.writeSimple("OK")
+	}
+}
+
+// info returns the text of an INFO reply for section.
+func (server *RESPServer) info(section string) string {
+	var t1, t2, b1, b2, targetMarker, limit int
+	var stats Stats
+	var diskStats DiskStats
+	server.cache.Do(func(arc *ARC) {
+		t1, t2, b1, b2 = arc.ListLens()
+		targetMarker = arc.TargetMarker()
+		limit = arc.MaxEntries()
+		stats = *arc.Stats()
+		diskStats = *arc.DiskStats()
+	})
+
+	var text strings.Builder
+	all := section == "all" || section == "everything" || section == "default"
+	if all || section == "server" {
+		fmt.Fprintf(&text, "# Server\r\nredis_version:7.0.0\r\narc_version:1.0.0\r\nuptime_in_seconds:%d\r\n\r\n",
+			int64(time.Since(server.started).Seconds()))
+	}
+	if all || section == "clients" {
+		fmt.Fprintf(&text, "# Clients\r\nconnected_clients:%d\r\n\r\n", atomic.LoadInt64(&server.currConns))
+	}
+	if all || section == "stats" {
+		fmt.Fprintf(&text, "# Stats\r\ntotal_connections_received:%d\r\ntotal_commands_processed:%d\r\n"+
+			"keyspace_hits:%d\r\nkeyspace_misses:%d\r\n\r\n",
+			atomic.LoadInt64(&server.totalConns), atomic.LoadInt64(&server.commands), stats.Hits, stats.Misses)
+	}
+	if all || section == "arc" {
+		fmt.Fprintf(&text, "# ARC\r\narc_limit:%d\r\narc_t1:%d\r\narc_t2:%d\r\narc_b1:%d\r\narc_b2:%d\r\n"+
+			"arc_target_marker:%d\r\narc_disk_bytes:%d\r\n\r\n",
+			limit, t1, t2, b1, b2, targetMarker, diskStats.UsedBytes)
+	}
+	if all || section == "keyspace" {
+		fmt.Fprintf(&text, "# Keyspace\r\ndb0:keys=%d\r\n\r\n", t1+t2)
+	}
+	return strings.TrimSuffix(text.String(), "\r\n")
+}
+
+// readCommand reads one request, either a RESP array of bulk strings
+// or an inline command, and returns its arguments.
+func (client *respConn) readCommand() ([][]byte, error) {
+	line, err := client.readLine()
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 || line[0] != '*' {
+		var args [][]byte
+		for _, field := range strings.Fields(string(line)) {
+			args = append(args, []byte(field))
+		}
+		return args, nil
+	}
+
+	count, err := strconv.Atoi(string(line[1:]))
+	if err != nil || count > maxRESPArgs {
+		return nil, errRESPProtocol
+	}
+	// The slices grow as arguments arrive, rather than as the header claims.
+	args := make([][]byte, 0, min(max(count, 0), 16))
+	for i := 0; i < count; i++ {
+		line, err := client.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if len(line) == 0 || line[0] != '$' {
+			return nil, errRESPProtocol
+		}
+		size, err := strconv.Atoi(string(line[1:]))
+		if err != nil || size < 0 || size > maxRESPBulkLen {
+			return nil, errRESPProtocol
+		}
+		// Read the payload in chunks, so that a length alone allocates nothing.
+		var arg bytes.Buffer
+		if _, err := io.CopyN(&arg, client.reader, int64(size)+2); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		if !bytes.HasSuffix(arg.Bytes(), []byte("\r\n")) {
+			return nil, errRESPProtocol
+		}
+		args = append(args, arg.Bytes()[:size])
+	}
+	return args, nil
+}
+
+// expireTime returns n units as a duration, and false if that overflows.
+func expireTime(n int64, unit time.Duration) (time.Duration, bool) {
+	if n > math.MaxInt64/int64(unit) || n < math.MinInt64/int64(unit) {
+		return 0, false
+	}
+	return time.Duration(n) * unit, true
+}
+
+// readLine reads a line, without its line ending.
+func (client *respConn) readLine() ([]byte, error) {
+	line, err := client.reader.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		return nil, errRESPProtocol
+	}
+	if err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(line, "\r\n"), nil
+}
+
+func (client *respConn) writeSimple(s string) {
+	fmt.Fprintf(client.writer, "+%s\r\n", s)
+}
+
+func (client *respConn) writeError(s string) {
+	fmt.Fprintf(client.writer, "-%s\r\n", s)
+}
+
+func (client *respConn) writeInt(n int64) {
+	fmt.Fprintf(client.writer, ":%d\r\n", n)
+}
+
+func (client *respConn) writeBulk(b []byte) {
+	fmt.Fprintf(client.writer, "$%d\r\n", len(b))
+	client.writer.Write(b)
+	client.writer.WriteString("\r\n")
+}
+
+// writeNull writes a null reply: RESP3's null, or RESP2's null bulk string.
+func (client *respConn) writeNull() {
+	if client.proto == 3 {
+		client.writer.WriteString("_\r\n")
+	} else {
+		client.writer.WriteString("$-1\r\n")
+	}
+}
+
+func (client *respConn) writeArrayLen(n int) {
+	fmt.Fprintf(client.writer, "*%d\r\n", n)
+}
+
+// writeMapLen starts a map of n pairs: a RESP3 map, or a flat RESP2 array.
+func (client *respConn) writeMapLen(n int) {
+	if client.proto == 3 {
+		fmt.Fprintf(client.writer, "%%%d\r\n", n)
+	} else {
+		client.writeArrayLen(2 * n)
+	}
+}
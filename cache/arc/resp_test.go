@@ -0,0 +1,240 @@
+package arc
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// respError is an error reply read by respClient.
+type respError string
+
+// respClient is a minimal RESP client for testing over loopback.
+type respClient struct {
+	t      *testing.T
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// startRESPServer serves a new SyncARC over loopback and returns a client connected to it.
+func startRESPServer(t *testing.T, limit int, opts ...Option) *respClient {
+	cache, err := NewSyncARC(limit, append([]Option{WithDirectory(t.TempDir())}, opts...)...)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	server := NewRESPServer(cache)
+	go server.Serve(listener)
+	t.Cleanup(func() { server.Close() })
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return &respClient{t, conn, bufio.NewReader(conn)}
+}
+
+// do sends a command and returns its reply.
+func (client *respClient) do(args ...string) interface{} {
+	request := fmt.Sprintf("*%d\r\n", len(args))
+	for _, arg := range args {
+		request += fmt.Sprintf("$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := client.conn.Write([]byte(request)); err != nil {
+		client.t.Fatalf("err: %v", err)
+	}
+	reply, err := client.read()
+	if err != nil {
+		client.t.Fatalf("%v: %v", args, err)
+	}
+	return reply
+}
+
+// read reads one reply, converting it to a Go value.
+func (client *respClient) read() (interface{}, error) {
+	line, err := client.reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return respError(line[1:]), nil
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '_':
+		return nil, nil
+	case '$':
+		size, _ := strconv.Atoi(line[1:])
+		if size < 0 {
+			return nil, nil
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(client.reader, data); err != nil {
+			return nil, err
+		}
+		return string(data[:size]), nil
+	case '*', '%':
+		n, _ := strconv.Atoi(line[1:])
+		if line[0] == '%' {
+			n *= 2
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			if items[i], err = client.read(); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("bad reply %q", line)
+}
+
+func (client *respClient) expect(want interface{}, args ...string) {
+	if got := client.do(args...); fmt.Sprint(got) != fmt.Sprint(want) {
+		client.t.Fatalf("%v: got %#v, want %#v", args, got, want)
+	}
+}
+
+// Tests the key commands over RESP2
+func TestRESP_Commands(t *testing.T) {
+	client := startRESPServer(t, 16)
+
+	client.expect("PONG", "PING")
+	client.expect("OK", "SET", "a", "1")
+	client.expect("1", "GET", "a")
+	client.expect(nil, "GET", "missing")
+	client.expect(nil, "SET", "a", "2", "NX")
+	client.expect("1", "SET", "a", "2", "GET")
+	client.expect(nil, "SET", "b", "2", "XX")
+	client.expect(int64(1), "EXISTS", "a", "b")
+	client.expect(int64(1), "DBSIZE")
+
+	client.expect(int64(-1), "TTL", "a")
+	client.expect(int64(-2), "TTL", "missing")
+	client.expect(int64(1), "EXPIRE", "a", "100")
+	client.expect(int64(100), "TTL", "a")
+	client.expect("OK", "SET", "a", "3", "KEEPTTL")
+	client.expect(int64(100), "TTL", "a")
+	client.expect("OK", "SET", "a", "4")
+	client.expect(int64(-1), "TTL", "a")
+	client.expect(int64(0), "EXPIRE", "missing", "100")
+
+	client.expect("OK", "SET", "short", "x", "PX", "1")
+	time.Sleep(5 * time.Millisecond)
+	client.expect(nil, "GET", "short")
+	client.expect(int64(1), "EXPIRE", "a", "0")
+	client.expect(int64(0), "EXISTS", "a")
+
+	client.expect("OK", "SET", "c", "1")
+	client.expect("OK", "SET", "d", "1")
+	client.expect(int64(2), "DEL", "c", "d", "missing")
+	client.expect("OK", "SET", "e", "1")
+	client.expect("OK", "FLUSHDB")
+	client.expect(int64(0), "DBSIZE")
+
+	client.expect(respError("ERR unknown command 'NOPE'"), "NOPE")
+	client.expect(respError("ERR wrong number of arguments for 'get' command"), "GET")
+	client.expect(respError("ERR syntax error"), "SET", "a", "1", "NX", "XX")
+	client.expect(respError("ERR value is not an integer or out of range"), "EXPIRE", "a", "soon")
+
+	// TTLs too large for a time.Duration are refused, rather than deleting the key
+	client.expect("OK", "SET", "big", "1")
+	client.expect(respError("ERR invalid expire time in 'expire' command"), "EXPIRE", "big", "10000000000")
+	client.expect(respError("ERR invalid expire time in 'pexpire' command"), "PEXPIRE", "big", "10000000000000")
+	client.expect(respError("ERR invalid expire time in 'set' command"), "SET", "big", "2", "EX", "10000000000")
+	client.expect("1", "GET", "big")
+	client.expect(int64(-1), "TTL", "big")
+}
+
+// Tests that SET answers a key turned away by the admission filter as not set
+func TestRESP_NotAdmitted(t *testing.T) {
+	client := startRESPServer(t, 16, WithAdmissionFilter(0.001))
+
+	client.expect(nil, "SET", "a", "1")
+	client.expect(nil, "GET", "a")
+	client.expect("OK", "SET", "a", "1")
+	client.expect("1", "GET", "a")
+}
+
+// Tests that DEL only counts keys EXISTS finds, not ghosts
+func TestRESP_DelGhost(t *testing.T) {
+	client := startRESPServer(t, 2)
+
+	client.expect("OK", "SET", "a", "1")
+	client.expect("1", "GET", "a")
+	client.expect("OK", "SET", "b", "2")
+	// Evicts b from T1 into B1
+	client.expect("OK", "SET", "c", "3")
+	client.expect(int64(0), "EXISTS", "b")
+	client.expect(int64(1), "DEL", "b", "c")
+}
+
+// Tests that a bulk length alone does not make the server allocate the payload
+func TestRESP_BulkLenAllocation(t *testing.T) {
+	request := "*1\r\n$60000000\r\nshort"
+	client := &respConn{reader: bufio.NewReader(strings.NewReader(request))}
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	if _, err := client.readCommand(); err == nil {
+		t.Fatalf("truncated payload accepted")
+	}
+	runtime.ReadMemStats(&after)
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1<<20 {
+		t.Fatalf("allocated %d bytes for a 5-byte payload", allocated)
+	}
+}
+
+// Tests switching to RESP3 with HELLO
+func TestRESP_Hello(t *testing.T) {
+	client := startRESPServer(t, 16)
+
+	client.expect(nil, "GET", "missing")
+	reply := client.do("HELLO", "3")
+	fields, ok := reply.([]interface{})
+	if !ok || len(fields) != 10 || fields[4] != "proto" || fields[5] != int64(3) {
+		t.Fatalf("bad hello reply: %#v", reply)
+	}
+	// RESP3 nulls are read back as nil too, but are sent as "_"
+	client.conn.Write([]byte("*2\r\n$3\r\nGET\r\n$7\r\nmissing\r\n"))
+	if line, _ := client.reader.ReadString('\n'); line != "_\r\n" {
+		t.Fatalf("bad resp3 null: %q", line)
+	}
+	client.expect(respError("NOPROTO unsupported protocol version"), "HELLO", "4")
+}
+
+// Tests that INFO reports the ARC's lists, target marker and stats
+func TestRESP_Info(t *testing.T) {
+	client := startRESPServer(t, 2)
+
+	client.do("SET", "a", "1")
+	client.do("GET", "a")
+	client.do("SET", "b", "1")
+	// Evicts b into B1
+	client.do("SET", "c", "1")
+	client.do("GET", "missing")
+
+	info, _ := client.do("INFO").(string)
+	for _, want := range []string{"arc_t1:1", "arc_t2:1", "arc_b1:1", "arc_b2:0",
+		"arc_target_marker:0", "keyspace_hits:1", "keyspace_misses:1", "db0:keys=2"} {
+		if !strings.Contains(info, want+"\r\n") {
+			t.Fatalf("info missing %q:\n%s", want, info)
+		}
+	}
+	arcOnly, _ := client.do("INFO", "arc").(string)
+	if !strings.HasPrefix(arcOnly, "# ARC") || strings.Contains(arcOnly, "# Stats") {
+		t.Fatalf("bad arc section:\n%s", arcOnly)
+	}
+}
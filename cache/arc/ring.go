@@ -0,0 +1,103 @@
+package arc
+
+import (
+	"hash/crc32"
+	"sort"
+	"strconv"
+)
+
+// A HashRing assigns keys to peers by consistent hashing, so that adding or
+// removing a peer only moves the keys that peer owned or comes to own.
+// Each peer is placed on the ring at several points to even out the load.
+// A HashRing is not safe for concurrent use.
+type HashRing struct {
+	replicas int
+	// Points on the ring in increasing order, and the peer at each.
+	points []uint32
+	owners map[uint32]string
+	peers  map[string]bool
+}
+
+// NewHashRing returns an empty ring that places each peer at replicas points.
+func NewHashRing(replicas int) *HashRing {
+	if replicas < 1 {
+		replicas = 1
+	}
+	var ring HashRing
+	ring.replicas = replicas
+	ring.owners = make(map[uint32]string)
+	ring.peers = make(map[string]bool)
+	return &ring
+}
+
+// Add adds peers to the ring, ignoring any already on it.
+func (ring *HashRing) Add(peers ...string) {
+	for _, peer := range peers {
+		if ring.peers[peer] {
+			continue
+		}
+		ring.peers[peer] = true
+		for i := 0; i < ring.replicas; i++ {
+			point := crc32.ChecksumIEEE([]byte(strconv.Itoa(i) + peer))
+			// On the rare collision, the lesser peer keeps the point
+			// so the ring doesn't depend on the order peers were added in.
+			if owner, ok := ring.owners[point]; ok {
+				if owner < peer {
+					continue
+				}
+			} else {
+				ring.points = append(ring.points, point)
+			}
+			ring.owners[point] = peer
+		}
+	}
+	sort.Slice(ring.points, func(i, j int) bool { return ring.points[i] < ring.points[j] })
+}
+
+// Remove removes peers from the ring.
+func (ring *HashRing) Remove(peers ...string) {
+	removed := false
+	for _, peer := range peers {
+		if ring.peers[peer] {
+			delete(ring.peers, peer)
+			removed = true
+		}
+	}
+	if !removed {
+		return
+	}
+	// Rebuild the ring, so points another peer lost in a collision are restored.
+	remaining := ring.Peers()
+	ring.points = nil
+	ring.owners = make(map[uint32]string)
+	ring.peers = make(map[string]bool)
+	ring.Add(remaining...)
+}
+
+// Get returns the peer that owns key, or "" if the ring is empty.
+func (ring *HashRing) Get(key string) string {
+	if len(ring.points) == 0 {
+		return ""
+	}
+	hash := crc32.ChecksumIEEE([]byte(key))
+	i := sort.Search(len(ring.points), func(i int) bool { return ring.points[i] >= hash })
+	if i == len(ring.points) {
+		i = 0
+	}
+	return ring.owners[ring.points[i]]
+}
+
+// Peers returns the peers on the ring in sorted order.
+func (ring *HashRing) Peers() []string {
+	peers := make([]string, 0, len(ring.peers))
+	for peer := range ring.peers {
+		peers = append(peers, peer)
+	}
+	sort.Strings(peers)
+	return peers
+}
+
+// Len returns the number of peers on the ring.
+func (ring *HashRing) Len() int {
+	return len(ring.peers)
+}
@@ -0,0 +1,129 @@
+package arc
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+// defaultSmallRatio is the share of an S3-FIFO cache given to the small queue,
+// from Yang et al.'s recommendation.
+const defaultSmallRatio = 0.1
+
+// s3fifoMaxFreq is the most uses an S3-FIFO key's counter records.
+const s3fifoMaxFreq = 3
+
+// s3fifoPolicy is S3-FIFO, as Yang et al. describe it.
+// New keys enter S, a small FIFO, and most of them, used once, leave it
+// without ever reaching M, the main FIFO. A key used again while in S moves to M
+// when it reaches the end of S, and the others are remembered in a ghost FIFO,
+// like ARC's B1, so that a quick return goes straight to M.
+// M is a FIFO with reinsertion: a key reaching its end that has been used since
+// it was last there goes back to the start, with one use less.
+// A hit only counts a use, so it needs no list operations.
+type s3fifoPolicy struct {
+	// The FIFOs, oldest key at the back. Keys are never moved by a hit.
+	small *LRU
+	main  *LRU
+	// Keys evicted from S, oldest at the back, holding as many as M.
+	ghostList *LRU
+	entries   map[string]*s3fifoEntry
+	// Target size of S.
+	smallSize int
+}
+
+// An s3fifoEntry counts the uses of a resident key since it entered its queue.
+type s3fifoEntry struct {
+	freq int32
+}
+
+var _ ConcurrentHitPolicy = (*s3fifoPolicy)(nil)
+
+// NewS3FIFO returns a cache with room for limit entries managed by S3-FIFO,
+// where small is the share of the cache given to the small queue.
+// The "s3fifo" policy uses 0.1.
+func NewS3FIFO(limit int, small float64, opts ...Option) (*PolicyCache, error) {
+	if small <= 0 || small >= 1 {
+		return nil, errors.New("arc: S3-FIFO needs 0 < small < 1")
+	}
+	if limit <= 0 {
+		return nil, errors.New("Capacity must be greater than zero")
+	}
+	return newPolicyCache("s3fifo", newS3FIFOPolicy(limit, small), limit, opts)
+}
+
+func newS3FIFOPolicy(limit int, small float64) *s3fifoPolicy {
+	var policy s3fifoPolicy
+	policy.smallSize = min(max(int(float64(limit)*small), 1), limit)
+	policy.small = NewLRU(limit + 1)
+	policy.main = NewLRU(limit + 1)
+	policy.ghostList = NewLRU(max(limit-policy.smallSize, 1))
+	policy.entries = make(map[string]*s3fifoEntry)
+	return &policy
+}
+
+func (policy *s3fifoPolicy) OnHit(key string) {
+	policy.OnHitConcurrent(key)
+}
+
+// OnHitConcurrent counts a use of key, up to s3fifoMaxFreq.
+func (policy *s3fifoPolicy) OnHitConcurrent(key string) {
+	entry, ok := policy.entries[key]
+	if !ok {
+		return
+	}
+	for {
+		freq := atomic.LoadInt32(&entry.freq)
+		if freq >= s3fifoMaxFreq || atomic.CompareAndSwapInt32(&entry.freq, freq, freq+1) {
+			return
+		}
+	}
+}
+
+func (policy *s3fifoPolicy) OnMiss(key string) {}
+
+func (policy *s3fifoPolicy) OnInsert(key string) {
+	policy.entries[key] = &s3fifoEntry{}
+	if contains(policy.ghostList, key) {
+		policy.ghostList.Remove(key)
+		policy.main.Set(key, nil)
+		return
+	}
+	policy.small.Set(key, nil)
+}
+
+func (policy *s3fifoPolicy) Victim() (key string, ok bool) {
+	for {
+		if policy.small.Len() >= policy.smallSize || policy.main.Len() == 0 {
+			key, ok = policy.small.Evict()
+			if !ok {
+				return "", false
+			}
+			entry := policy.entries[key]
+			if atomic.LoadInt32(&entry.freq) > 0 {
+				// Used again while in S: it earns a place in M, with its count cleared.
+				atomic.StoreInt32(&entry.freq, 0)
+				policy.main.Set(key, nil)
+				continue
+			}
+			delete(policy.entries, key)
+			policy.ghostList.Set(key, nil)
+			return key, true
+		}
+		key, _ = policy.main.Evict()
+		entry := policy.entries[key]
+		if freq := atomic.LoadInt32(&entry.freq); freq > 0 {
+			atomic.StoreInt32(&entry.freq, freq-1)
+			policy.main.Set(key, nil)
+			continue
+		}
+		delete(policy.entries, key)
+		return key, true
+	}
+}
+
+func (policy *s3fifoPolicy) Remove(key string) {
+	policy.small.Remove(key)
+	policy.main.Remove(key)
+	policy.ghostList.Remove(key)
+	delete(policy.entries, key)
+}
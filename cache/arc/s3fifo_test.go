@@ -0,0 +1,110 @@
+package arc
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// Computes hit ratio for accessing random entries in an S3-FIFO cache
+func BenchmarkS3FIFO_Rand(b *testing.B) {
+	l, err := NewPolicyCache("s3fifo", 8192)
+	if err != nil {
+		b.Fatalf("err: %v", err)
+	}
+
+	trace := make([]int64, b.N*2)
+	for i := 0; i < b.N*2; i++ {
+		trace[i] = rand.Int63() % 32768
+	}
+
+	b.ResetTimer()
+
+	for i := 0; i < 2*b.N; i++ {
+		s := fmt.Sprintf("%v", trace[i])
+		if i%2 == 0 {
+			b := make([]byte, 8)
+			binary.LittleEndian.PutUint64(b, uint64(trace[i]))
+
+			l.Set(s, b)
+		} else {
+			l.Get(s)
+		}
+	}
+	hits := l.stats.Hits
+	misses := l.stats.Misses
+	b.Logf("hit: %d miss: %d ratio: %f", hits, misses, float64(hits)/float64(misses))
+}
+
+// Compute hit ratio for a linear sequence of accesses
+func BenchmarkS3FIFO_Freq(b *testing.B) {
+	l, err := NewPolicyCache("s3fifo", 8192)
+	if err != nil {
+		b.Fatalf("err: %v", err)
+	}
+
+	trace := make([]int64, b.N*2)
+	for i := 0; i < b.N*2; i++ {
+		if i%2 == 0 {
+			trace[i] = rand.Int63() % 16384
+		} else {
+			trace[i] = rand.Int63() % 32768
+		}
+	}
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		b := make([]byte, 8)
+		binary.LittleEndian.PutUint64(b, uint64(trace[i]))
+		s := fmt.Sprintf("%v", trace[i])
+
+		l.Set(s, b)
+	}
+	for i := 0; i < b.N; i++ {
+		s := fmt.Sprintf("%v", trace[i])
+		l.Get(s)
+	}
+	hits := l.stats.Hits
+	misses := l.stats.Misses
+	b.Logf("hit: %d miss: %d ratio: %f", hits, misses, float64(hits)/float64(misses))
+}
+
+// Tests that keys used once pass through S into the ghost FIFO, and keys used again reach M
+func TestS3FIFO_Queues(t *testing.T) {
+	// a is used again while in S, then k pushes a and b out of S
+	trace := []string{"a", "a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k"}
+	policy := newS3FIFOPolicy(10, 0.1)
+	SimulatePolicy(policy, 10, trace)
+	if !contains(policy.main, "a") || contains(policy.small, "a") {
+		t.Fatalf("a should be in M")
+	}
+	if !contains(policy.ghostList, "b") || contains(policy.small, "b") {
+		t.Fatalf("b should be a ghost")
+	}
+	// Requested again as a ghost, b goes straight to M
+	policy = newS3FIFOPolicy(10, 0.1)
+	SimulatePolicy(policy, 10, append(trace, "b"))
+	if !contains(policy.main, "b") || contains(policy.ghostList, "b") || !contains(policy.ghostList, "c") {
+		t.Fatalf("b should be in M, and c a ghost")
+	}
+	if _, err := NewS3FIFO(10, 0); err == nil {
+		t.Fatalf("bad small accepted")
+	}
+}
+
+// Tests that S3-FIFO matches ARC on Zipf-heavy traces
+func TestS3FIFO_HitRatio(t *testing.T) {
+	for _, kind := range []string{"zipf", "scan"} {
+		trace, _ := NewTrace(kind, 200000, 50000, 1)
+		for _, limit := range []int{100, 1000, 5000} {
+			arc, _ := Simulate("arc", limit, trace)
+			s3fifo, _ := Simulate("s3fifo", limit, trace)
+			t.Logf("%s limit %4d: arc %.4f s3fifo %.4f", kind, limit, arc.HitRatio(), s3fifo.HitRatio())
+			if s3fifo.HitRatio() < arc.HitRatio()-0.005 {
+				t.Fatalf("%s limit %d: s3fifo %.4f below arc %.4f", kind, limit, s3fifo.HitRatio(), arc.HitRatio())
+			}
+		}
+	}
+}
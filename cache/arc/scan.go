@@ -0,0 +1,62 @@
+package arc
+
+// SetScan is like Set, for keys streamed through by a batch job or a scan,
+// which are unlikely to be used again. A new key goes to the least recently used
+// end of T1, to be evicted first, and leaves no ghost in B1 when it is, so a scan
+// pushes out neither the working set nor the ghosts that adapt the cache to it.
+// A key in B1 or B2 is added the same way, without adapting the target marker,
+// and a key already in the cache has its value replaced where it is.
+// A key added by SetScan is treated as any other once it is used by Get.
+// The admission filter, if there is one, is not consulted.
+func (arc *ARC) SetScan(key string, value []byte) (ok bool) {
+	arc.dropIfExpired(key)
+	if arc.origin != nil {
+		if arc.writeMode == WriteThrough {
+			if err := arc.storeToOrigin(key, value); err != nil {
+				return false
+			}
+		} else {
+			arc.dirty[key] = true
+		}
+	}
+	delete(arc.expiry, key)
+	return arc.setScan(key, value)
+}
+
+// setScan is SetScan without writing to the origin.
+func (arc *ARC) setScan(key string, value []byte) (ok bool) {
+	if arc.t1List.update(key, value) || arc.t2List.update(key, value) {
+		arc.WriteToDisk(key, value)
+		return true
+	}
+	// A ghost is not a hit for a scan: it is added as a new key,
+	// and its file on disk overwritten.
+	arc.b1List.Remove(key)
+	arc.b2List.Remove(key)
+	arc.curve.remove(key)
+	if back, _, found := arc.t1List.Back(); found && arc.scanned[back] && arc.Len() >= arc.limit {
+		// Another scanned key makes room, leaving the ghost lists alone.
+		arc.evictFromCache(arc.t1List)
+		arc.forget(back)
+	} else {
+		arc.makeRoom(key)
+	}
+	arc.t1List.setBack(key, value)
+	arc.scanned[key] = true
+	arc.WriteToDisk(key, value)
+	return true
+}
+
+// GetScan is like Get, for keys read by a batch job or a scan. A hit does not
+// count as a use, so the key is not promoted to T2, and a ghost is treated as a
+// miss, as if it were not in the cache directory at all. A value read through from
+// the origin is added as a new key by SetScan. Hits and misses are counted as by Get.
+func (arc *ARC) GetScan(key string) (value []byte, ok bool) {
+	arc.dropIfExpired(key)
+	if value, ok := arc.CheckCache(key); ok {
+		arc.stats.Hits++
+		return value, true
+	}
+	arc.stats.Misses++
+	return arc.loadFromOrigin(key, arc.setScan)
+}
@@ -0,0 +1,113 @@
+package arc
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+// Builds an ARC of 100 entries whose working set fills T2 with 40 keys,
+// T1 with 60 and B1 with 20 ghosts
+func newWorkingSetARC(t *testing.T) *ARC {
+	arc, err := NewARC(100, WithDirectory(t.TempDir()))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 40; i++ {
+		arc.Set(fmt.Sprint("t2-", i), []byte("v"))
+		arc.Get(fmt.Sprint("t2-", i))
+	}
+	for i := 0; i < 80; i++ {
+		arc.Set(fmt.Sprint("t1-", i), []byte("v"))
+	}
+	if t1, t2, b1, b2 := arc.ListLens(); t1 != 60 || t2 != 40 || b1 != 20 || b2 != 0 {
+		t.Fatalf("bad working set: %d %d %d %d", t1, t2, b1, b2)
+	}
+	return arc
+}
+
+// Tests that the working set, its ghosts and the target marker survive a full scan with SetScan
+func TestARC_SetScan(t *testing.T) {
+	arc := newWorkingSetARC(t)
+	for i := 0; i < 1000; i++ {
+		if !arc.SetScan(fmt.Sprint("scan", i), []byte("s")) {
+			t.Fatalf("scan%d not set", i)
+		}
+	}
+	// One T1 key made room for the first scanned key, which made room for the rest
+	if t1, t2, b1, b2 := arc.ListLens(); t1 != 60 || t2 != 40 || b1 != 21 || b2 != 0 {
+		t.Fatalf("scan disturbed the lists: %d %d %d %d", t1, t2, b1, b2)
+	}
+	if arc.TargetMarker() != 0 {
+		t.Fatalf("scan adapted the target marker: %d", arc.TargetMarker())
+	}
+	for i := 0; i < 40; i++ {
+		if _, ok := arc.CheckCache(fmt.Sprint("t2-", i)); !ok {
+			t.Fatalf("t2-%d pushed out by the scan", i)
+		}
+	}
+	for i := 21; i < 80; i++ {
+		if _, ok := arc.CheckCache(fmt.Sprint("t1-", i)); !ok {
+			t.Fatalf("t1-%d pushed out by the scan", i)
+		}
+	}
+	for i := 0; i < 20; i++ {
+		if _, ok := arc.CheckCacheDirectory(fmt.Sprint("t1-", i)); !ok {
+			t.Fatalf("ghost t1-%d pushed out by the scan", i)
+		}
+	}
+	// Only the last scanned key is left, and nothing else is on disk
+	if _, ok := arc.CheckCache("scan999"); !ok {
+		t.Fatalf("last scanned key missing")
+	}
+	entries, _ := os.ReadDir(arc.cacheDirectory)
+	if len(entries) != 60+40+21 {
+		t.Fatalf("%d files on disk", len(entries))
+	}
+
+	// Used again, a scanned key is promoted as usual
+	arc.Get("scan999")
+	if _, t2, _, _ := arc.ListLens(); t2 != 41 || arc.scanned["scan999"] {
+		t.Fatalf("used scanned key not promoted")
+	}
+}
+
+// Tests that the same scan with Set pushes out T1 and its ghosts
+func TestARC_SetScanComparedWithSet(t *testing.T) {
+	arc := newWorkingSetARC(t)
+	for i := 0; i < 1000; i++ {
+		arc.Set(fmt.Sprint("scan", i), []byte("s"))
+	}
+	for i := 0; i < 80; i++ {
+		if _, ok := arc.CheckCacheDirectory(fmt.Sprint("t1-", i)); ok {
+			t.Fatalf("t1-%d survived a scan with Set", i)
+		}
+	}
+}
+
+// Tests that GetScan neither promotes hits nor adapts to ghost hits
+func TestARC_GetScan(t *testing.T) {
+	origin := newMapOrigin()
+	arc, err := NewARC(10, WithDirectory(t.TempDir()), WithOrigin(origin, WriteThrough))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	arc.Set("a", []byte("1"))
+	if value, ok := arc.GetScan("a"); !ok || string(value) != "1" {
+		t.Fatalf("bad hit: %q", value)
+	}
+	if t1, t2, _, _ := arc.ListLens(); t1 != 1 || t2 != 0 {
+		t.Fatalf("GetScan promoted a hit")
+	}
+	// A miss is read through and added at the end of T1
+	origin.Store("b", []byte("2"))
+	if value, ok := arc.GetScan("b"); !ok || string(value) != "2" {
+		t.Fatalf("bad read through: %q", value)
+	}
+	if key, _, _ := arc.t1List.Back(); key != "b" || !arc.scanned["b"] {
+		t.Fatalf("read-through value not added as scanned")
+	}
+	if stats := arc.Stats(); stats.Hits != 1 || stats.Misses != 1 {
+		t.Fatalf("bad stats: %+v", stats)
+	}
+}
@@ -0,0 +1,107 @@
+package arc
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrServerClosed is returned by Serve after Close has been called.
+var ErrServerClosed = errors.New("arc: server closed")
+
+// A connServer accepts connections and serves each on a goroutine of its own,
+// keeping track of them so that Close can shut everything down.
+// The protocol servers in this package embed one.
+type connServer struct {
+	handle func(conn net.Conn)
+
+	currConns, totalConns int64
+
+	mu        sync.Mutex
+	listeners map[net.Listener]bool
+	conns     map[net.Conn]bool
+	closed    bool
+	wg        sync.WaitGroup
+}
+
+// init prepares server to serve each connection with handle,
+// which returns once it is done with the connection.
+func (server *connServer) init(handle func(conn net.Conn)) {
+	server.handle = handle
+	server.listeners = make(map[net.Listener]bool)
+	server.conns = make(map[net.Conn]bool)
+}
+
+// Serve accepts connections on listener until Close is called,
+// serving each on its own goroutine.
+func (server *connServer) Serve(listener net.Listener) error {
+	server.mu.Lock()
+	if server.closed {
+		server.mu.Unlock()
+		return ErrServerClosed
+	}
+	server.listeners[listener] = true
+	server.mu.Unlock()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			server.mu.Lock()
+			closed := server.closed
+			server.mu.Unlock()
+			if closed {
+				return ErrServerClosed
+			}
+			return err
+		}
+		if !server.track(conn) {
+			conn.Close()
+			return ErrServerClosed
+		}
+		go server.serveConn(conn)
+	}
+}
+
+// Close stops all listeners and closes all connections,
+// waiting for their goroutines to finish.
+func (server *connServer) Close() error {
+	server.mu.Lock()
+	server.closed = true
+	for listener := range server.listeners {
+		listener.Close()
+	}
+	for conn := range server.conns {
+		conn.Close()
+	}
+	server.mu.Unlock()
+	server.wg.Wait()
+	return nil
+}
+
+// track records conn as open, unless the server is closed.
+func (server *connServer) track(conn net.Conn) bool {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if server.closed {
+		return false
+	}
+	server.conns[conn] = true
+	server.wg.Add(1)
+	atomic.AddInt64(&server.currConns, 1)
+	atomic.AddInt64(&server.totalConns, 1)
+	return true
+}
+
+// serveConn hands conn to the handler and forgets it once the handler returns.
+func (server *connServer) serveConn(conn net.Conn) {
+	defer func() {
+		conn.Close()
+		server.mu.Lock()
+		delete(server.conns, conn)
+		server.mu.Unlock()
+		atomic.AddInt64(&server.currConns, -1)
+		server.wg.Done()
+	}()
+	server.handle(conn)
+}
@@ -0,0 +1,106 @@
+package arc
+
+import (
+	"errors"
+	"math/rand"
+	"strconv"
+)
+
+// Simulate replays trace against a new instance of the policy registered
+// under name, in a cache with room for limit entries, and returns the hits and misses.
+// Every miss is followed by inserting the key, as in a demand-paged cache.
+// No values are stored, so traces of millions of keys replay in seconds.
+func Simulate(name string, limit int, trace []string) (*Stats, error) {
+	policy, err := NewPolicy(name, limit)
+	if err != nil {
+		return nil, err
+	}
+	return SimulatePolicy(policy, limit, trace), nil
+}
+
+// SimulatePolicy is like Simulate, for a policy that is already constructed.
+func SimulatePolicy(policy Policy, limit int, trace []string) *Stats {
+	stats := NewStats()
+	resident := make(map[string]bool)
+	for _, key := range trace {
+		if resident[key] {
+			stats.Hits++
+			policy.OnHit(key)
+			continue
+		}
+		stats.Misses++
+		policy.OnMiss(key)
+		policy.OnInsert(key)
+		resident[key] = true
+		for len(resident) > limit {
+			victim, ok := policy.Victim()
+			if !ok {
+				break
+			}
+			delete(resident, victim)
+		}
+	}
+	return stats
+}
+
+// HitRatio returns the fraction of lookups that hit.
+func (stats *Stats) HitRatio() float64 {
+	if stats.Hits+stats.Misses == 0 {
+		return 0
+	}
+	return float64(stats.Hits) / float64(stats.Hits+stats.Misses)
+}
+
+// TraceKinds lists the kinds of trace NewTrace generates.
+var TraceKinds = []string{"rand", "zipf", "loop", "scan"}
+
+// NewTrace returns a synthetic trace of length lookups drawn from keys distinct keys:
+//
+//	rand  keys drawn uniformly at random
+//	zipf  keys drawn from a Zipf distribution, so a few are very popular
+//	loop  the keys in order, over and over
+//	scan  a Zipf working set of a tenth of the keys, interrupted by long
+//	      sequential scans of keys that are never used again
+//
+// The same seed gives the same trace.
+func NewTrace(kind string, length int, keys int, seed int64) ([]string, error) {
+	if keys <= 0 || length < 0 {
+		return nil, errors.New("arc: trace needs keys")
+	}
+	random := rand.New(rand.NewSource(seed))
+	trace := make([]string, length)
+	switch kind {
+	case "rand":
+		for i := range trace {
+			trace[i] = strconv.Itoa(random.Intn(keys))
+		}
+	case "zipf":
+		zipf := rand.NewZipf(random, 1.1, 1, uint64(keys-1))
+		for i := range trace {
+			trace[i] = strconv.FormatUint(zipf.Uint64(), 10)
+		}
+	case "loop":
+		for i := range trace {
+			trace[i] = strconv.Itoa(i % keys)
+		}
+	case "scan":
+		hot := max(keys/10, 1)
+		zipf := rand.NewZipf(random, 1.1, 1, uint64(hot-1))
+		scanned := 0
+		for i := 0; i < length; {
+			// Alternate between working-set traffic and a scan of fresh keys.
+			for burst := 0; burst < 4*hot && i < length; burst++ {
+				trace[i] = strconv.FormatUint(zipf.Uint64(), 10)
+				i++
+			}
+			for burst := 0; burst < 2*hot && i < length; burst++ {
+				trace[i] = "scan" + strconv.Itoa(scanned)
+				scanned++
+				i++
+			}
+		}
+	default:
+		return nil, errors.New("arc: unknown trace kind " + kind)
+	}
+	return trace, nil
+}
@@ -0,0 +1,342 @@
+package arc
+
+import (
+	"errors"
+	"math"
+)
+
+// A SizedARC is an ARC for values of different sizes: its capacity, the target size
+// of T1 and the bounds on the ghost lists are counted in bytes rather than entries.
+// Each ghost remembers the size of the value it would bring back, so that a ghost hit
+// moves the target marker by that many bytes, scaled as ARC scales its steps.
+// A value larger than the whole cache is never admitted, and a large value in T1
+// is evicted before it can push the frequently used keys out of T2.
+// As with ARC, values are mirrored in the on-disk cache directory, from which
+// ghost hits are read back.
+type SizedARC struct {
+	t1List sizedList
+	t2List sizedList
+	b1List sizedList
+	b2List sizedList
+	// Sizes of the keys in the cache directory. For a ghost,
+	// the number of bytes it would take up again if it were re-admitted.
+	sizes map[string]int
+	// Keys in T1 or T2 whose values could not be written to the on-disk cache
+	// directory. They leave no ghost when evicted, as it could not be read back.
+	offDisk map[string]bool
+	// The name of the on-disk cache directory, and the store that reads and writes it.
+	cacheDirectory string
+	disk           *diskStore
+	// Target size of T1 in bytes, which adapts depending on ghost list hits.
+	targetMarker int
+	// The maximum number of bytes of values the cache can hold.
+	// T1 + T2 <= maxBytes, L1 <= maxBytes and L1 + L2 <= 2*maxBytes, all in bytes.
+	maxBytes int
+	stats    Stats
+}
+
+// A sizedList is one of the lists of a SizedARC, with the number of bytes it holds.
+type sizedList struct {
+	keys  *LRU
+	bytes int
+}
+
+var _ Cache = (*SizedARC)(nil)
+
+// NewSizedARC returns a pointer to a new SizedARC with a capacity to store maxBytes bytes of values.
+func NewSizedARC(maxBytes int, opts ...Option) (*SizedARC, error) {
+	if maxBytes <= 0 {
+		return nil, errors.New("Capacity must be greater than zero")
+	}
+	var arc SizedARC
+	for _, list := range []*sizedList{&arc.t1List, &arc.t2List, &arc.b1List, &arc.b2List} {
+		// The lists are bounded by bytes, not entries.
+		list.keys = NewLRU(math.MaxInt)
+	}
+	arc.sizes = make(map[string]int)
+	arc.offDisk = make(map[string]bool)
+	conf := newConfig(opts)
+	arc.cacheDirectory = conf.dir
+	disk, err := newDiskStore(arc.cacheDirectory, conf)
+	if err != nil {
+		return nil, err
+	}
+	arc.disk = disk
+	arc.maxBytes = maxBytes
+	return &arc, nil
+}
+
+// sizeOf returns the number of bytes value takes up in a SizedARC.
+// Empty values take up one byte, so that the cache holds a bounded number of keys.
+func sizeOf(value []byte) int {
+	return max(len(value), 1)
+}
+
+// MaxStorage returns the maximum number of bytes of values the cache can store.
+func (arc *SizedARC) MaxStorage() int {
+	return arc.maxBytes
+}
+
+// RemainingStorage returns the number of unused bytes available in the cache.
+func (arc *SizedARC) RemainingStorage() int {
+	return arc.maxBytes - arc.t1List.bytes - arc.t2List.bytes
+}
+
+// ListBytes returns the number of bytes in each of T1, T2, B1 and B2.
+// For B1 and B2, these are the bytes their keys would take up if re-admitted.
+func (arc *SizedARC) ListBytes() (t1, t2, b1, b2 int) {
+	return arc.t1List.bytes, arc.t2List.bytes, arc.b1List.bytes, arc.b2List.bytes
+}
+
+// ListLens returns the number of entries in each of T1, T2, B1 and B2.
+func (arc *SizedARC) ListLens() (t1, t2, b1, b2 int) {
+	return arc.t1List.keys.Len(), arc.t2List.keys.Len(), arc.b1List.keys.Len(), arc.b2List.keys.Len()
+}
+
+// TargetMarker returns the current target size of T1, in bytes.
+func (arc *SizedARC) TargetMarker() int {
+	return arc.targetMarker
+}
+
+// Get returns the value associated with the given key, if it exists.
+// This operation counts as a "use" for that key-value pair
+// ok is true if a value was found and false otherwise.
+// The value of a ghost is read back from the on-disk cache directory and re-admitted;
+// that counts as a miss, but the value is returned.
+func (arc *SizedARC) Get(key string) (value []byte, ok bool) {
+	if value, ok := arc.Check(key); ok {
+		arc.stats.Hits++
+		arc.set(key, value)
+		return value, true
+	}
+	arc.stats.Misses++
+	if !contains(arc.b1List.keys, key) && !contains(arc.b2List.keys, key) {
+		return nil, false
+	}
+	value, err := arc.disk.read(key)
+	if err != nil {
+		// A ghost whose file cannot be read back is dropped.
+		arc.drop(key)
+		return nil, false
+	}
+	if !arc.set(key, value) {
+		return nil, false
+	}
+	return value, true
+}
+
+// Check returns the value associated with the given key, if it exists.
+// This operation DOES NOT counts as a "use" for that key-value pair
+// ok is true if a value was found and false otherwise.
+func (arc *SizedARC) Check(key string) (value []byte, ok bool) {
+	if value, ok = arc.t1List.keys.Check(key); ok {
+		return value, true
+	}
+	return arc.t2List.keys.Check(key)
+}
+
+// Remove removes and returns the value associated with the given key, if it exists.
+// This erases the key from the cache lists, the ghost lists and the on-disk cache directory.
+// ok is true if a value was found and false otherwise
+func (arc *SizedARC) Remove(key string) (value []byte, ok bool) {
+	value, ok = arc.Check(key)
+	arc.drop(key)
+	return value, ok
+}
+
+// drop removes key from whichever list holds it, and forgets it.
+func (arc *SizedARC) drop(key string) {
+	for _, list := range []*sizedList{&arc.t1List, &arc.t2List, &arc.b1List, &arc.b2List} {
+		if contains(list.keys, key) {
+			arc.take(list, key)
+			arc.forget(key)
+			return
+		}
+	}
+}
+
+// Set associates the given value with the given key, possibly evicting values
+// to make room. Returns true if the binding was added successfully, else false.
+// A value larger than MaxStorage is not added, and any old binding for key is removed.
+func (arc *SizedARC) Set(key string, value []byte) (ok bool) {
+	if sizeOf(value) > arc.maxBytes {
+		arc.drop(key)
+		return false
+	}
+	if !arc.set(key, value) {
+		return false
+	}
+	var makeRoom func() bool
+	if arc.disk.quotaPolicy == QuotaEvictGhosts {
+		makeRoom = arc.evictGhost
+	}
+	if err := arc.disk.write(key, value, makeRoom); err != nil {
+		arc.offDisk[key] = true
+	} else {
+		delete(arc.offDisk, key)
+	}
+	return true
+}
+
+// evictGhost evicts the least recently used ghost of the list holding more bytes
+// to free disk space, and reports whether there was a ghost to evict.
+func (arc *SizedARC) evictGhost() bool {
+	ghosts := &arc.b1List
+	if arc.b2List.bytes > arc.b1List.bytes {
+		ghosts = &arc.b2List
+	}
+	evicted, ok := arc.evictLRU(ghosts)
+	if ok {
+		arc.forget(evicted)
+		arc.disk.stats.QuotaEvictions++
+	}
+	return ok
+}
+
+// set is Set without writing to the on-disk cache directory,
+// for values that are there already.
+func (arc *SizedARC) set(key string, value []byte) (ok bool) {
+	size := sizeOf(value)
+	if size > arc.maxBytes {
+		return false
+	}
+	switch {
+	// Case I: key is found in T1 or T2
+	case contains(arc.t1List.keys, key):
+		arc.take(&arc.t1List, key)
+		arc.makeRoom(size, false)
+		arc.add(&arc.t2List, key, value, size)
+	case contains(arc.t2List.keys, key):
+		arc.take(&arc.t2List, key)
+		arc.makeRoom(size, false)
+		arc.add(&arc.t2List, key, value, size)
+	// Case II: key is found in B1. T1 would have held it with as many more bytes
+	// as it would take up, so the target marker moves by that much.
+	case contains(arc.b1List.keys, key):
+		step := arc.sizes[key] * max(arc.b2List.bytes/arc.b1List.bytes, 1)
+		arc.targetMarker = min(arc.maxBytes, arc.targetMarker+step)
+		arc.take(&arc.b1List, key)
+		arc.makeRoom(size, false)
+		arc.add(&arc.t2List, key, value, size)
+	// Case III: key is found in B2
+	case contains(arc.b2List.keys, key):
+		step := arc.sizes[key] * max(arc.b1List.bytes/arc.b2List.bytes, 1)
+		arc.targetMarker = max(0, arc.targetMarker-step)
+		arc.take(&arc.b2List, key)
+		arc.makeRoom(size, true)
+		arc.add(&arc.t2List, key, value, size)
+	// Case IV: key is not found
+	default:
+		// Case (A): keep L1 to maxBytes, dropping ghosts first, then keys of T1 outright.
+		for arc.t1List.bytes+arc.b1List.bytes+size > arc.maxBytes {
+			list := &arc.b1List
+			if list.keys.Len() == 0 {
+				list = &arc.t1List
+			}
+			evicted, ok := arc.evictLRU(list)
+			if !ok {
+				break
+			}
+			arc.forget(evicted)
+		}
+		// Case (B): keep the cache directory to twice maxBytes.
+		for arc.t1List.bytes+arc.t2List.bytes+arc.b1List.bytes+arc.b2List.bytes+size > 2*arc.maxBytes {
+			evicted, ok := arc.evictLRU(&arc.b2List)
+			if !ok {
+				break
+			}
+			arc.forget(evicted)
+		}
+		arc.makeRoom(size, false)
+		arc.add(&arc.t1List, key, value, size)
+	}
+	arc.trimGhosts()
+	return true
+}
+
+// makeRoom demotes keys from T1 and T2 to their ghost lists
+// until size more bytes fit in the cache. inB2 says whether
+// the key being added was a B2 ghost.
+func (arc *SizedARC) makeRoom(size int, inB2 bool) {
+	for arc.t1List.bytes+arc.t2List.bytes+size > arc.maxBytes {
+		t1 := arc.t1List.bytes
+		fromT1 := arc.t1List.keys.Len() > 0 && (t1 > arc.targetMarker || (inB2 && t1 >= arc.targetMarker))
+		from, to := &arc.t2List, &arc.b2List
+		if fromT1 || arc.t2List.keys.Len() == 0 {
+			from, to = &arc.t1List, &arc.b1List
+		}
+		evicted, ok := arc.evictLRU(from)
+		if !ok {
+			return
+		}
+		if arc.offDisk[evicted] {
+			arc.forget(evicted)
+			continue
+		}
+		arc.add(to, evicted, nil, arc.sizes[evicted])
+	}
+}
+
+// trimGhosts drops the least recently used ghosts while L1 is over maxBytes,
+// or the cache directory over twice maxBytes, as a value that grew may leave them.
+func (arc *SizedARC) trimGhosts() {
+	for arc.t1List.bytes+arc.b1List.bytes > arc.maxBytes && arc.b1List.keys.Len() > 0 {
+		evicted, _ := arc.evictLRU(&arc.b1List)
+		arc.forget(evicted)
+	}
+	for arc.t1List.bytes+arc.t2List.bytes+arc.b1List.bytes+arc.b2List.bytes > 2*arc.maxBytes {
+		list := &arc.b2List
+		if list.keys.Len() == 0 {
+			list = &arc.b1List
+		}
+		evicted, ok := arc.evictLRU(list)
+		if !ok {
+			return
+		}
+		arc.forget(evicted)
+	}
+}
+
+// add puts key at the front of list, taking up size bytes.
+func (arc *SizedARC) add(list *sizedList, key string, value []byte, size int) {
+	list.keys.Set(key, value)
+	list.bytes += size
+	arc.sizes[key] = size
+}
+
+// take removes key from list, leaving its size recorded.
+func (arc *SizedARC) take(list *sizedList, key string) {
+	list.keys.Remove(key)
+	list.bytes -= arc.sizes[key]
+}
+
+// evictLRU takes the least recently used key off list.
+func (arc *SizedARC) evictLRU(list *sizedList) (key string, ok bool) {
+	key, _, ok = list.keys.Back()
+	if ok {
+		arc.take(list, key)
+	}
+	return key, ok
+}
+
+// forget drops what the SizedARC keeps about a key that has left the cache directory.
+func (arc *SizedARC) forget(key string) {
+	delete(arc.sizes, key)
+	delete(arc.offDisk, key)
+	arc.disk.remove(key)
+}
+
+// Len returns the number of bindings in the cache.
+func (arc *SizedARC) Len() int {
+	return arc.t1List.keys.Len() + arc.t2List.keys.Len()
+}
+
+// Stats returns statistics about how many search hits and misses have occurred.
+func (arc *SizedARC) Stats() *Stats {
+	return &arc.stats
+}
+
+// DiskStats returns statistics about the values written to the on-disk cache directory.
+func (arc *SizedARC) DiskStats() *DiskStats {
+	return &arc.disk.stats
+}
@@ -0,0 +1,161 @@
+package arc
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+// Checks the byte bounds of a SizedARC's lists
+func checkSizedBounds(t *testing.T, arc *SizedARC) {
+	t.Helper()
+	t1, t2, b1, b2 := arc.ListBytes()
+	limit := arc.MaxStorage()
+	if t1+t2 > limit || t1+b1 > limit || t1+t2+b1+b2 > 2*limit {
+		t.Fatalf("bounds broken: t1 %d t2 %d b1 %d b2 %d limit %d", t1, t2, b1, b2, limit)
+	}
+	if arc.RemainingStorage() != limit-t1-t2 {
+		t.Fatalf("bad remaining storage: %d", arc.RemainingStorage())
+	}
+	if arc.TargetMarker() < 0 || arc.TargetMarker() > limit {
+		t.Fatalf("bad target marker: %d", arc.TargetMarker())
+	}
+}
+
+// Tests that a SizedARC counts bytes, and brings ghosts back from disk
+func TestSizedARC(t *testing.T) {
+	arc, err := NewSizedARC(100, WithDirectory(t.TempDir()))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	arc.Set("a", make([]byte, 40))
+	arc.Set("b", make([]byte, 40))
+	if arc.Len() != 2 || arc.RemainingStorage() != 20 {
+		t.Fatalf("bad size: %d, %d bytes left", arc.Len(), arc.RemainingStorage())
+	}
+	// b moves to T2, and c needs 30 bytes, so a, in T1 and over its target, becomes a ghost
+	arc.Get("b")
+	arc.Set("c", make([]byte, 30))
+	if _, ok := arc.Check("a"); ok {
+		t.Fatalf("a should have been evicted")
+	}
+	if t1, t2, b1, _ := arc.ListBytes(); t1 != 30 || t2 != 40 || b1 != 40 {
+		t.Fatalf("bad list bytes: t1 %d t2 %d b1 %d", t1, t2, b1)
+	}
+	// The ghost's value is read back from disk, and the target marker grows by its size
+	if value, ok := arc.Get("a"); !ok || len(value) != 40 {
+		t.Fatalf("ghost a not brought back: %v", ok)
+	}
+	if arc.TargetMarker() != 40 {
+		t.Fatalf("bad target marker: %d", arc.TargetMarker())
+	}
+	// Making room for it, b goes from T2 to B2, since T1 is within its new target
+	if t1, t2, b1, b2 := arc.ListLens(); t1 != 1 || t2 != 1 || b1 != 0 || b2 != 1 {
+		t.Fatalf("bad lists: %d %d %d %d", t1, t2, b1, b2)
+	}
+	checkSizedBounds(t, arc)
+
+	// A value larger than the cache is refused, and leaves the cache as it was
+	before := arc.Len()
+	if arc.Set("huge", make([]byte, 101)) {
+		t.Fatalf("value larger than the cache accepted")
+	}
+	if arc.Len() != before {
+		t.Fatalf("refused value evicted entries")
+	}
+
+	if value, ok := arc.Remove("a"); !ok || len(value) != 40 {
+		t.Fatalf("bad remove")
+	}
+	if _, err := NewSizedARC(0); err == nil {
+		t.Fatalf("zero capacity accepted")
+	}
+}
+
+// Tests that a SizedARC meets its disk quota by evicting ghosts, and leaves no ghost
+// for a value that could not be written
+func TestSizedARC_DiskQuota(t *testing.T) {
+	probe, _ := NewSizedARC(100, WithDirectory(t.TempDir()))
+	probe.Set("a", make([]byte, 40))
+	quota := 2 * probe.DiskStats().UsedBytes
+
+	for _, policy := range []QuotaPolicy{QuotaEvictGhosts, QuotaSkip} {
+		arc, err := NewSizedARC(100, WithDirectory(t.TempDir()), WithDiskQuota(quota, policy))
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		arc.Set("a", make([]byte, 40))
+		arc.Set("b", make([]byte, 40))
+		arc.Get("b")
+		// a becomes a ghost, and c only fits on disk without it
+		arc.Set("c", make([]byte, 30))
+		stats := arc.DiskStats()
+		if policy == QuotaEvictGhosts {
+			if _, _, b1, _ := arc.ListLens(); stats.Skipped != 0 || stats.QuotaEvictions != 1 || b1 != 0 {
+				t.Fatalf("ghost not evicted for the quota: %+v, %d in b1", stats, b1)
+			}
+			continue
+		}
+		if stats.Skipped != 1 {
+			t.Fatalf("c not skipped: %+v", stats)
+		}
+		// Evicting c, which is not on disk, leaves no ghost
+		arc.Set("d", make([]byte, 40))
+		if contains(arc.b1List.keys, "c") || contains(arc.b2List.keys, "c") {
+			t.Fatalf("c left a ghost")
+		}
+		checkSizedBounds(t, arc)
+	}
+}
+
+// Tests that a stream of large values used once cannot push out small values in use
+func TestSizedARC_LargeValuesDoNotStarveSmall(t *testing.T) {
+	arc, err := NewSizedARC(100000, WithDirectory(t.TempDir()))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	small := []byte("small value")
+	for i := 0; i < 500; i++ {
+		// 100 small keys, about a kilobyte in all, used twice every fifth round,
+		// by which time an LRU would have let four large values push them out
+		for j := 0; i%5 == 0 && j < 200; j++ {
+			key := fmt.Sprint("small", j%100)
+			if _, ok := arc.Get(key); !ok {
+				arc.Set(key, small)
+			}
+		}
+		// and a new 30 KB value each round, never used again
+		arc.Set(fmt.Sprint("large", i), make([]byte, 30000))
+		checkSizedBounds(t, arc)
+	}
+	for j := 0; j < 100; j++ {
+		if value, ok := arc.Check(fmt.Sprint("small", j)); !ok || !bytes.Equal(value, small) {
+			t.Fatalf("small%d was pushed out", j)
+		}
+	}
+	// Every small key missed once, when it was first set
+	if stats := arc.Stats(); stats.Misses != 100 {
+		t.Fatalf("bad stats: %+v", stats)
+	}
+}
+
+// Tests that large ghosts move the target marker by their size, within the cache,
+// and that the byte bounds hold on a mixed workload
+func TestSizedARC_Adapt(t *testing.T) {
+	arc, err := NewSizedARC(10000, WithDirectory(t.TempDir()))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	trace, _ := NewTrace("zipf", 20000, 500, 1)
+	for i, key := range trace {
+		// Sizes vary with the key, from 1 byte to 2 KB
+		size := (len(key)*997 + i%7) % 2048
+		if _, ok := arc.Get(key); !ok {
+			arc.Set(key, make([]byte, size))
+		}
+		checkSizedBounds(t, arc)
+	}
+	if arc.Stats().Hits == 0 {
+		t.Fatalf("no hits")
+	}
+}
@@ -0,0 +1,142 @@
+package arc
+
+import (
+	"hash/fnv"
+	"math"
+)
+
+// keyHashes returns two independent hashes of key, from which any number
+// of hashes can be derived as h1 + i*h2, as Kirsch and Mitzenmacher show.
+func keyHashes(key string) (h1 uint32, h2 uint32) {
+	hash := fnv.New64a()
+	hash.Write([]byte(key))
+	sum := hash.Sum64()
+	return uint32(sum), uint32(sum>>32) | 1
+}
+
+// A bloomFilter is a set of keys that may report false positives, but never false negatives.
+type bloomFilter struct {
+	bits   []uint64
+	hashes int
+}
+
+// newBloomFilter returns a Bloom filter sized to hold n keys
+// with a false-positive rate of at most fpRate.
+func newBloomFilter(n int, fpRate float64) *bloomFilter {
+	n = max(n, 1)
+	if fpRate <= 0 || fpRate >= 1 {
+		fpRate = 0.01
+	}
+	// The optimal sizes: m = -n ln p / (ln 2)^2 bits and k = m/n ln 2 hashes.
+	m := int(math.Ceil(-float64(n) * math.Log(fpRate) / (math.Ln2 * math.Ln2)))
+	var filter bloomFilter
+	filter.bits = make([]uint64, (m+63)/64)
+	filter.hashes = max(int(math.Round(float64(len(filter.bits)*64)/float64(n)*math.Ln2)), 1)
+	return &filter
+}
+
+// add adds key to the filter, reporting whether it (or a false positive) was already there.
+func (filter *bloomFilter) add(key string) (present bool) {
+	h1, h2 := keyHashes(key)
+	size := uint32(len(filter.bits) * 64)
+	present = true
+	for i := 0; i < filter.hashes; i++ {
+		bit := (h1 + uint32(i)*h2) % size
+		if filter.bits[bit/64]&(1<<(bit%64)) == 0 {
+			present = false
+			filter.bits[bit/64] |= 1 << (bit % 64)
+		}
+	}
+	return present
+}
+
+// contains reports whether key may have been added to the filter.
+func (filter *bloomFilter) contains(key string) bool {
+	h1, h2 := keyHashes(key)
+	size := uint32(len(filter.bits) * 64)
+	for i := 0; i < filter.hashes; i++ {
+		bit := (h1 + uint32(i)*h2) % size
+		if filter.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// reset empties the filter.
+func (filter *bloomFilter) reset() {
+	for i := range filter.bits {
+		filter.bits[i] = 0
+	}
+}
+
+// sketchDepth is the number of rows in a countMinSketch.
+const sketchDepth = 4
+
+// sketchMax is the largest count a countMinSketch counter holds.
+const sketchMax = 15
+
+// A countMinSketch estimates how often each key has been seen, in a fixed space.
+// Counts never fall short, and overestimate only when keys share counters in every row.
+// Counters saturate at 15, and are halved once the sketch has been incremented
+// as many times as its sample size, so the counts follow changes in popularity.
+type countMinSketch struct {
+	rows [sketchDepth][]uint8
+	// Increments since the counters were last halved, and how many trigger it.
+	additions  int
+	sampleSize int
+}
+
+// newCountMinSketch returns a sketch for a cache of limit entries,
+// with four counters per entry in each row to keep collisions rare.
+func newCountMinSketch(limit int) *countMinSketch {
+	width := 1
+	for width < max(4*limit, 16) {
+		width *= 2
+	}
+	var sketch countMinSketch
+	for i := range sketch.rows {
+		sketch.rows[i] = make([]uint8, width)
+	}
+	sketch.sampleSize = 10 * max(limit, 1)
+	return &sketch
+}
+
+// increment counts one sighting of key, reporting whether the counters were then halved.
+func (sketch *countMinSketch) increment(key string) (aged bool) {
+	h1, h2 := keyHashes(key)
+	width := uint32(len(sketch.rows[0]))
+	for i := range sketch.rows {
+		counter := &sketch.rows[i][(h1+uint32(i)*h2)%width]
+		if *counter < sketchMax {
+			*counter++
+		}
+	}
+	sketch.additions++
+	if sketch.additions >= sketch.sampleSize {
+		sketch.age()
+		return true
+	}
+	return false
+}
+
+// estimate returns the number of sightings of key, or a little more.
+func (sketch *countMinSketch) estimate(key string) int {
+	h1, h2 := keyHashes(key)
+	width := uint32(len(sketch.rows[0]))
+	count := sketchMax
+	for i := range sketch.rows {
+		count = min(count, int(sketch.rows[i][(h1+uint32(i)*h2)%width]))
+	}
+	return count
+}
+
+// age halves every counter.
+func (sketch *countMinSketch) age() {
+	for i := range sketch.rows {
+		for j := range sketch.rows[i] {
+			sketch.rows[i][j] /= 2
+		}
+	}
+	sketch.additions /= 2
+}
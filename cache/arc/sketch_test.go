@@ -0,0 +1,62 @@
+package arc
+
+import (
+	"fmt"
+	"testing"
+)
+
+// Tests that a Bloom filter has no false negatives and about the false positives asked for
+func TestBloomFilter(t *testing.T) {
+	filter := newBloomFilter(10000, 0.01)
+	for i := 0; i < 10000; i++ {
+		if filter.add(fmt.Sprint("in", i)) && i == 0 {
+			t.Fatalf("empty filter contains a key")
+		}
+	}
+	for i := 0; i < 10000; i++ {
+		if !filter.contains(fmt.Sprint("in", i)) {
+			t.Fatalf("false negative for %d", i)
+		}
+	}
+	falsePositives := 0
+	for i := 0; i < 10000; i++ {
+		if filter.contains(fmt.Sprint("out", i)) {
+			falsePositives++
+		}
+	}
+	if falsePositives > 200 {
+		t.Fatalf("%d false positives in 10000", falsePositives)
+	}
+	filter.reset()
+	if filter.contains("in0") {
+		t.Fatalf("reset filter contains a key")
+	}
+}
+
+// Tests count-min estimates, saturation and aging
+func TestCountMinSketch(t *testing.T) {
+	sketch := newCountMinSketch(1000)
+	for i := 0; i < 5; i++ {
+		sketch.increment("five")
+	}
+	for i := 0; i < 40; i++ {
+		sketch.increment("many")
+	}
+	if n := sketch.estimate("five"); n < 5 || n > 6 {
+		t.Fatalf("bad estimate: %d", n)
+	}
+	if n := sketch.estimate("many"); n != sketchMax {
+		t.Fatalf("counter did not saturate: %d", n)
+	}
+	if n := sketch.estimate("never"); n > 1 {
+		t.Fatalf("bad estimate for an unseen key: %d", n)
+	}
+
+	aged := false
+	for i := 0; !aged; i++ {
+		aged = sketch.increment(fmt.Sprint(i))
+	}
+	if n := sketch.estimate("many"); n != sketchMax/2 {
+		t.Fatalf("counter not halved: %d", n)
+	}
+}
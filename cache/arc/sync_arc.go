@@ -0,0 +1,316 @@
+package arc
+
+import (
+	"sync"
+	"time"
+)
+
+// A SyncARC is an ARC that is safe for concurrent use by multiple goroutines.
+// Every operation holds a single lock, as even Get reorders the lists.
+type SyncARC struct {
+	mu   sync.Mutex
+	arc  *ARC
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewSyncARC returns a pointer to a new SyncARC with a capacity to store limited entries.
+// If opts include WithFlushInterval, dirty values are flushed on a goroutine
+// that runs until Close is called.
+func NewSyncARC(limit int, opts ...Option) (*SyncARC, error) {
+	arc, err := NewARC(limit, opts...)
+	if err != nil {
+		return nil, err
+	}
+	var sarc SyncARC
+	sarc.arc = arc
+	sarc.done = make(chan struct{})
+	if interval := newConfig(opts).flushInterval; interval > 0 && arc.origin != nil {
+		sarc.wg.Add(1)
+		go sarc.flushEvery(interval)
+	}
+	return &sarc, nil
+}
+
+// flushEvery flushes dirty values every interval until Close is called.
+func (sarc *SyncARC) flushEvery(interval time.Duration) {
+	defer sarc.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			sarc.Flush()
+		case <-sarc.done:
+			return
+		}
+	}
+}
+
+// Close stops the periodic flush, if any, and flushes the remaining dirty values.
+func (sarc *SyncARC) Close() error {
+	select {
+	case <-sarc.done:
+	default:
+		close(sarc.done)
+	}
+	sarc.wg.Wait()
+	return sarc.Flush()
+}
+
+// MaxEntries returns the maximum number of entries this cache can store
+func (sarc *SyncARC) MaxEntries() int {
+	sarc.mu.Lock()
+	defer sarc.mu.Unlock()
+	return sarc.arc.MaxEntries()
+}
+
+// RemainingSpaces returns the number of unused spaces available for entries in the cache
+func (sarc *SyncARC) RemainingSpaces() int {
+	sarc.mu.Lock()
+	defer sarc.mu.Unlock()
+	return sarc.arc.RemainingSpaces()
+}
+
+// Get returns the value associated with the given key, if it exists.
+// See ARC.Get.
+func (sarc *SyncARC) Get(key string) (value []byte, ok bool) {
+	sarc.mu.Lock()
+	defer sarc.mu.Unlock()
+	return sarc.arc.Get(key)
+}
+
+// CheckCache returns the value associated with the given key without counting a use.
+// Unlike ARC.CheckCache, it never returns an expired binding.
+func (sarc *SyncARC) CheckCache(key string) (value []byte, ok bool) {
+	sarc.mu.Lock()
+	defer sarc.mu.Unlock()
+	sarc.arc.dropIfExpired(key)
+	return sarc.arc.CheckCache(key)
+}
+
+// CheckCacheDirectory reports whether key is in the cache directory without counting a use.
+// Unlike ARC.CheckCacheDirectory, it never reports an expired binding.
+func (sarc *SyncARC) CheckCacheDirectory(key string) (value []byte, ok bool) {
+	sarc.mu.Lock()
+	defer sarc.mu.Unlock()
+	sarc.arc.dropIfExpired(key)
+	return sarc.arc.CheckCacheDirectory(key)
+}
+
+// Remove removes and returns the value associated with the given key, if it exists.
+// See ARC.Remove.
+func (sarc *SyncARC) Remove(key string) (value []byte, ok bool) {
+	sarc.mu.Lock()
+	defer sarc.mu.Unlock()
+	return sarc.arc.Remove(key)
+}
+
+// Set associates the given value with the given key.
+// See ARC.Set.
+func (sarc *SyncARC) Set(key string, value []byte) bool {
+	sarc.mu.Lock()
+	defer sarc.mu.Unlock()
+	return sarc.arc.Set(key, value)
+}
+
+// SetWithTTL associates the given value with the given key until ttl has passed.
+// See ARC.SetWithTTL.
+func (sarc *SyncARC) SetWithTTL(key string, value []byte, ttl time.Duration) bool {
+	sarc.mu.Lock()
+	defer sarc.mu.Unlock()
+	return sarc.arc.SetWithTTL(key, value, ttl)
+}
+
+// TrySet associates the given value with the given key until ttl has passed,
+// reporting why it did not. See ARC.TrySet.
+func (sarc *SyncARC) TrySet(key string, value []byte, ttl time.Duration) error {
+	sarc.mu.Lock()
+	defer sarc.mu.Unlock()
+	return sarc.arc.TrySet(key, value, ttl)
+}
+
+// SetScan associates the given value with the given key as part of a scan.
+// See ARC.SetScan.
+func (sarc *SyncARC) SetScan(key string, value []byte) bool {
+	sarc.mu.Lock()
+	defer sarc.mu.Unlock()
+	return sarc.arc.SetScan(key, value)
+}
+
+// GetScan returns the value associated with the given key as part of a scan.
+// See ARC.GetScan.
+func (sarc *SyncARC) GetScan(key string) (value []byte, ok bool) {
+	sarc.mu.Lock()
+	defer sarc.mu.Unlock()
+	return sarc.arc.GetScan(key)
+}
+
+// GetMany returns the values associated with the given keys, holding the lock once.
+// See ARC.GetMany.
+func (sarc *SyncARC) GetMany(keys []string) map[string][]byte {
+	sarc.mu.Lock()
+	defer sarc.mu.Unlock()
+	return sarc.arc.GetMany(keys)
+}
+
+// SetMany associates each value with its key, holding the lock once.
+// See ARC.SetMany.
+func (sarc *SyncARC) SetMany(values map[string][]byte) (added int) {
+	sarc.mu.Lock()
+	defer sarc.mu.Unlock()
+	return sarc.arc.SetMany(values)
+}
+
+// RemoveMany removes the given keys, holding the lock once.
+// See ARC.RemoveMany.
+func (sarc *SyncARC) RemoveMany(keys []string) map[string][]byte {
+	sarc.mu.Lock()
+	defer sarc.mu.Unlock()
+	return sarc.arc.RemoveMany(keys)
+}
+
+// Peek returns the value associated with the given key and the list it is in,
+// without counting a use. Unlike ARC.Peek, it never returns an expired binding.
+func (sarc *SyncARC) Peek(key string) (value []byte, loc Location, ok bool) {
+	sarc.mu.Lock()
+	defer sarc.mu.Unlock()
+	sarc.arc.dropIfExpired(key)
+	return sarc.arc.Peek(key)
+}
+
+// Contains reports whether the given key is in the cache without counting a use.
+// Unlike ARC.Contains, it never reports an expired binding.
+func (sarc *SyncARC) Contains(key string) bool {
+	sarc.mu.Lock()
+	defer sarc.mu.Unlock()
+	sarc.arc.dropIfExpired(key)
+	return sarc.arc.Contains(key)
+}
+
+// Keys returns the keys in each of T1, T2, B1 and B2.
+// See ARC.Keys.
+func (sarc *SyncARC) Keys() (t1, t2, b1, b2 []string) {
+	sarc.mu.Lock()
+	defer sarc.mu.Unlock()
+	return sarc.arc.Keys()
+}
+
+// Range calls fn for every key in the cache directory.
+// The lock is held until Range returns, so fn must not use the SyncARC.
+// See ARC.Range.
+func (sarc *SyncARC) Range(fn func(key string, value []byte, loc Location) bool) {
+	sarc.mu.Lock()
+	defer sarc.mu.Unlock()
+	sarc.arc.Range(fn)
+}
+
+// Expire sets the binding for key to expire after ttl.
+// See ARC.Expire.
+func (sarc *SyncARC) Expire(key string, ttl time.Duration) bool {
+	sarc.mu.Lock()
+	defer sarc.mu.Unlock()
+	return sarc.arc.Expire(key, ttl)
+}
+
+// TTL returns the time left until the binding for key expires.
+// See ARC.TTL.
+func (sarc *SyncARC) TTL(key string) (ttl time.Duration, hasTTL bool, ok bool) {
+	sarc.mu.Lock()
+	defer sarc.mu.Unlock()
+	return sarc.arc.TTL(key)
+}
+
+// HitCurve estimates the hit ratio the cache would have had with room for more entries.
+// See ARC.HitCurve.
+func (sarc *SyncARC) HitCurve(n int) []CurvePoint {
+	sarc.mu.Lock()
+	defer sarc.mu.Unlock()
+	return sarc.arc.HitCurve(n)
+}
+
+// Resize changes the maximum number of entries the cache can store.
+// See ARC.Resize.
+func (sarc *SyncARC) Resize(newLimit int) error {
+	sarc.mu.Lock()
+	defer sarc.mu.Unlock()
+	return sarc.arc.Resize(newLimit)
+}
+
+// Purge removes every binding and ghost from the cache.
+// See ARC.Purge.
+func (sarc *SyncARC) Purge() {
+	sarc.mu.Lock()
+	defer sarc.mu.Unlock()
+	sarc.arc.Purge()
+}
+
+// Do calls fn with the underlying ARC while holding the lock,
+// so that fn can combine several operations atomically.
+// fn must not retain the ARC or call methods of sarc.
+func (sarc *SyncARC) Do(fn func(arc *ARC)) {
+	sarc.mu.Lock()
+	defer sarc.mu.Unlock()
+	fn(sarc.arc)
+}
+
+// Flush stores every dirty value in the origin.
+// See ARC.Flush.
+func (sarc *SyncARC) Flush() error {
+	sarc.mu.Lock()
+	defer sarc.mu.Unlock()
+	return sarc.arc.Flush()
+}
+
+// Len returns the number of bindings in the cache.
+func (sarc *SyncARC) Len() int {
+	sarc.mu.Lock()
+	defer sarc.mu.Unlock()
+	return sarc.arc.Len()
+}
+
+// ListLens returns the number of entries in each of T1, T2, B1 and B2.
+func (sarc *SyncARC) ListLens() (t1, t2, b1, b2 int) {
+	sarc.mu.Lock()
+	defer sarc.mu.Unlock()
+	return sarc.arc.ListLens()
+}
+
+// TargetMarker returns the current target size of T1.
+func (sarc *SyncARC) TargetMarker() int {
+	sarc.mu.Lock()
+	defer sarc.mu.Unlock()
+	return sarc.arc.TargetMarker()
+}
+
+// Stats returns a snapshot of the cache's hit and miss statistics.
+func (sarc *SyncARC) Stats() *Stats {
+	sarc.mu.Lock()
+	defer sarc.mu.Unlock()
+	stats := *sarc.arc.Stats()
+	return &stats
+}
+
+// DiskStats returns a snapshot of the cache's on-disk statistics.
+func (sarc *SyncARC) DiskStats() *DiskStats {
+	sarc.mu.Lock()
+	defer sarc.mu.Unlock()
+	stats := *sarc.arc.DiskStats()
+	return &stats
+}
+
+// OriginStats returns a snapshot of the cache's origin statistics.
+func (sarc *SyncARC) OriginStats() *OriginStats {
+	sarc.mu.Lock()
+	defer sarc.mu.Unlock()
+	stats := *sarc.arc.OriginStats()
+	return &stats
+}
+
+// AdmissionStats returns a snapshot of the cache's admission filter statistics.
+func (sarc *SyncARC) AdmissionStats() *AdmissionStats {
+	sarc.mu.Lock()
+	defer sarc.mu.Unlock()
+	stats := *sarc.arc.AdmissionStats()
+	return &stats
+}
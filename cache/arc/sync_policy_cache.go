@@ -0,0 +1,115 @@
+package arc
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// A SyncPolicyCache is a PolicyCache that is safe for concurrent use.
+// If its policy is a ConcurrentHitPolicy, such as "car", "clockpro" or "s3fifo",
+// hits are served under a read lock, so that they proceed in parallel;
+// everything else takes the write lock.
+type SyncPolicyCache struct {
+	mu    sync.RWMutex
+	cache *PolicyCache
+	// The policy, if its hits may be recorded under the read lock.
+	concurrent ConcurrentHitPolicy
+	// Hits served under the read lock, which cannot update cache.stats.
+	readHits int64
+}
+
+// NewSyncPolicyCache returns a SyncPolicyCache with room for limit entries,
+// managed by the policy registered under name.
+func NewSyncPolicyCache(name string, limit int, opts ...Option) (*SyncPolicyCache, error) {
+	cache, err := NewPolicyCache(name, limit, opts...)
+	if err != nil {
+		return nil, err
+	}
+	var scache SyncPolicyCache
+	scache.cache = cache
+	scache.concurrent, _ = cache.policy.(ConcurrentHitPolicy)
+	return &scache, nil
+}
+
+// Get returns the value associated with the given key, if it exists.
+// This operation counts as a "use" for that key-value pair.
+func (scache *SyncPolicyCache) Get(key string) (value []byte, ok bool) {
+	if scache.concurrent != nil {
+		scache.mu.RLock()
+		value, ok = scache.cache.values[key]
+		// Keys with a TTL take the write lock, which may drop them.
+		_, expires := scache.cache.expiry[key]
+		if ok && !expires {
+			atomic.AddInt64(&scache.readHits, 1)
+			scache.concurrent.OnHitConcurrent(key)
+			scache.mu.RUnlock()
+			return value, true
+		}
+		scache.mu.RUnlock()
+	}
+	scache.mu.Lock()
+	defer scache.mu.Unlock()
+	return scache.cache.Get(key)
+}
+
+// Check returns the value associated with the given key, if it exists,
+// without counting a use.
+func (scache *SyncPolicyCache) Check(key string) (value []byte, ok bool) {
+	scache.mu.Lock()
+	defer scache.mu.Unlock()
+	return scache.cache.Check(key)
+}
+
+// Remove removes and returns the value associated with the given key, if it exists.
+func (scache *SyncPolicyCache) Remove(key string) (value []byte, ok bool) {
+	scache.mu.Lock()
+	defer scache.mu.Unlock()
+	return scache.cache.Remove(key)
+}
+
+// Set associates the given value with the given key, possibly evicting values
+// to make room. Returns true if the binding was added successfully, else false.
+func (scache *SyncPolicyCache) Set(key string, value []byte) bool {
+	scache.mu.Lock()
+	defer scache.mu.Unlock()
+	return scache.cache.Set(key, value)
+}
+
+// SetWithTTL is like Set, but the binding expires after ttl.
+func (scache *SyncPolicyCache) SetWithTTL(key string, value []byte, ttl time.Duration) bool {
+	scache.mu.Lock()
+	defer scache.mu.Unlock()
+	return scache.cache.SetWithTTL(key, value, ttl)
+}
+
+// Do calls fn with the underlying PolicyCache while holding the lock,
+// so that several operations can be applied atomically.
+func (scache *SyncPolicyCache) Do(fn func(cache *PolicyCache)) {
+	scache.mu.Lock()
+	defer scache.mu.Unlock()
+	fn(scache.cache)
+}
+
+// Flush stores every dirty value in the origin.
+func (scache *SyncPolicyCache) Flush() error {
+	scache.mu.Lock()
+	defer scache.mu.Unlock()
+	return scache.cache.Flush()
+}
+
+// Len returns the number of bindings in the cache.
+func (scache *SyncPolicyCache) Len() int {
+	scache.mu.RLock()
+	defer scache.mu.RUnlock()
+	return scache.cache.Len()
+}
+
+// Stats returns a snapshot of the cache's hit and miss statistics.
+func (scache *SyncPolicyCache) Stats() *Stats {
+	scache.mu.Lock()
+	defer scache.mu.Unlock()
+	stats := *scache.cache.Stats()
+	stats.Hits += int(atomic.LoadInt64(&scache.readHits))
+	return &stats
+}
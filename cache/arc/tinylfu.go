@@ -0,0 +1,141 @@
+package arc
+
+import (
+	"errors"
+)
+
+// Default shares of a W-TinyLFU cache, from Einziger, Friedman and Manes:
+// 1% for the window, and 80% of the rest for the protected segment.
+const (
+	defaultWindowRatio    = 0.01
+	tinyLFUProtectedRatio = 0.8
+)
+
+// tinyLFUPolicy is W-TinyLFU, as Einziger, Friedman and Manes describe it.
+// New keys enter a small window LRU. A key leaving the window competes for a place
+// in the main cache, a segmented LRU, against the key the main cache would evict:
+// whichever the frequency sketch says has been seen more often stays.
+// A doorkeeper Bloom filter takes each key's first sighting, so one-off keys
+// never reach the sketch, and both are aged together.
+type tinyLFUPolicy struct {
+	window    *LRU
+	probation *LRU
+	protected *LRU
+	// Target sizes of the window, the main cache and its protected segment.
+	windowSize    int
+	mainSize      int
+	protectedSize int
+	sketch        *countMinSketch
+	doorkeeper    *bloomFilter
+}
+
+// NewTinyLFU returns a cache with room for limit entries managed by W-TinyLFU,
+// where window is the share of the cache given to the window LRU.
+// The "tinylfu" policy uses 0.01.
+func NewTinyLFU(limit int, window float64, opts ...Option) (*PolicyCache, error) {
+	if window <= 0 || window >= 1 {
+		return nil, errors.New("arc: W-TinyLFU needs 0 < window < 1")
+	}
+	if limit <= 0 {
+		return nil, errors.New("Capacity must be greater than zero")
+	}
+	return newPolicyCache("tinylfu", newTinyLFUPolicy(limit, window), limit, opts)
+}
+
+func newTinyLFUPolicy(limit int, window float64) *tinyLFUPolicy {
+	var policy tinyLFUPolicy
+	policy.windowSize = min(max(int(float64(limit)*window), 1), limit)
+	policy.mainSize = limit - policy.windowSize
+	policy.protectedSize = int(float64(policy.mainSize) * tinyLFUProtectedRatio)
+	policy.window = NewLRU(limit + 1)
+	policy.probation = NewLRU(limit + 1)
+	policy.protected = NewLRU(limit + 1)
+	policy.sketch = newCountMinSketch(limit)
+	// The doorkeeper sees every key added to the sketch between agings.
+	policy.doorkeeper = newBloomFilter(policy.sketch.sampleSize, 0.01)
+	return &policy
+}
+
+// record counts a sighting of key.
+func (policy *tinyLFUPolicy) record(key string) {
+	if !policy.doorkeeper.add(key) {
+		return
+	}
+	if policy.sketch.increment(key) {
+		policy.doorkeeper.reset()
+	}
+}
+
+// frequency estimates how often key has been seen recently.
+func (policy *tinyLFUPolicy) frequency(key string) int {
+	count := policy.sketch.estimate(key)
+	if policy.doorkeeper.contains(key) {
+		count++
+	}
+	return count
+}
+
+func (policy *tinyLFUPolicy) OnHit(key string) {
+	policy.record(key)
+	switch {
+	case contains(policy.window, key):
+		policy.window.Set(key, nil)
+	case contains(policy.probation, key):
+		// A second use in the main cache earns a protected place.
+		policy.probation.Remove(key)
+		policy.protected.Set(key, nil)
+		if policy.protected.Len() > policy.protectedSize {
+			demoted, _ := policy.protected.Evict()
+			policy.probation.Set(demoted, nil)
+		}
+	case contains(policy.protected, key):
+		policy.protected.Set(key, nil)
+	}
+}
+
+// OnMiss does nothing: the sighting is recorded by OnInsert,
+// so a miss followed by a Set counts once.
+func (policy *tinyLFUPolicy) OnMiss(key string) {}
+
+func (policy *tinyLFUPolicy) OnInsert(key string) {
+	policy.record(key)
+	policy.window.Set(key, nil)
+}
+
+func (policy *tinyLFUPolicy) Victim() (key string, ok bool) {
+	for policy.window.Len() > policy.windowSize {
+		candidate, _, _ := policy.window.Back()
+		mainLen := policy.probation.Len() + policy.protected.Len()
+		if mainLen < policy.mainSize {
+			// The main cache has room, so the candidate moves in unopposed.
+			policy.window.Evict()
+			policy.probation.Set(candidate, nil)
+			continue
+		}
+		victim, _, ok := policy.probation.Back()
+		if !ok {
+			victim, _, ok = policy.protected.Back()
+		}
+		if ok && policy.frequency(candidate) > policy.frequency(victim) {
+			policy.probation.Remove(victim)
+			policy.protected.Remove(victim)
+			policy.window.Evict()
+			policy.probation.Set(candidate, nil)
+			return victim, true
+		}
+		return policy.window.Evict()
+	}
+	if key, ok = policy.probation.Evict(); ok {
+		return key, true
+	}
+	if key, ok = policy.protected.Evict(); ok {
+		return key, true
+	}
+	return policy.window.Evict()
+}
+
+func (policy *tinyLFUPolicy) Remove(key string) {
+	policy.window.Remove(key)
+	policy.probation.Remove(key)
+	policy.protected.Remove(key)
+}
@@ -0,0 +1,44 @@
+package arc
+
+import (
+	"testing"
+)
+
+// Tests W-TinyLFU against ARC and LRU on Zipf-heavy traces.
+// On a fixed popularity ranking the two adaptive policies come out about even,
+// since keys near the cut-off are seen too rarely for the sketch to rank them.
+func TestTinyLFU_Zipf(t *testing.T) {
+	for _, kind := range []string{"zipf", "scan"} {
+		trace, _ := NewTrace(kind, 200000, 50000, 1)
+		for _, limit := range []int{100, 1000, 5000} {
+			lru, _ := Simulate("lru", limit, trace)
+			arc, _ := Simulate("arc", limit, trace)
+			tinylfu, _ := Simulate("tinylfu", limit, trace)
+			t.Logf("%s limit %4d: lru %.4f arc %.4f tinylfu %.4f", kind, limit, lru.HitRatio(), arc.HitRatio(), tinylfu.HitRatio())
+			if tinylfu.HitRatio() < arc.HitRatio()-0.01 || tinylfu.HitRatio() <= lru.HitRatio() {
+				t.Fatalf("%s limit %d: tinylfu %.4f, arc %.4f, lru %.4f", kind, limit, tinylfu.HitRatio(), arc.HitRatio(), lru.HitRatio())
+			}
+		}
+	}
+}
+
+// Tests that a popular key beats a one-off key for a place in the main cache
+func TestTinyLFU_Admission(t *testing.T) {
+	policy := newTinyLFUPolicy(4, 0.25)
+	trace := []string{"a", "b", "c", "d", "a", "b", "c", "d", "a", "b", "c", "d"}
+	// Each one-off key passes through the window and loses to the popular ones
+	for i := 0; i < 20; i++ {
+		trace = append(trace, "once"+string(rune('A'+i)))
+	}
+	trace = append(trace, "a", "b", "c")
+	stats := SimulatePolicy(policy, 4, trace)
+	if stats.Hits < 11 {
+		t.Fatalf("popular keys were evicted: %+v", stats)
+	}
+	if policy.window.Len() != 1 || policy.probation.Len()+policy.protected.Len() != 3 {
+		t.Fatalf("bad segments: %d %d %d", policy.window.Len(), policy.probation.Len(), policy.protected.Len())
+	}
+	if _, err := NewTinyLFU(4, 0); err == nil {
+		t.Fatalf("bad window accepted")
+	}
+}
@@ -0,0 +1,64 @@
+package arc
+
+import (
+	"time"
+)
+
+// Expired bindings are dropped lazily, the next time Get, Set or Remove
+// is called with their key; until then they still count towards Len.
+
+// SetWithTTL is like Set, but the binding expires after ttl.
+// A ttl of zero or less sets a binding that never expires.
+func (arc *ARC) SetWithTTL(key string, value []byte, ttl time.Duration) (ok bool) {
+	return arc.TrySet(key, value, ttl) == nil
+}
+
+// Expire sets the binding for key to expire after ttl.
+// A ttl of zero clears any expiry, and a negative ttl drops the binding at once.
+// ok is false if key is not in the cache.
+func (arc *ARC) Expire(key string, ttl time.Duration) (ok bool) {
+	arc.dropIfExpired(key)
+	if _, inCache := arc.CheckCache(key); !inCache {
+		return false
+	}
+	switch {
+	case ttl > 0:
+		arc.expiry[key] = time.Now().Add(ttl)
+	case ttl == 0:
+		delete(arc.expiry, key)
+	default:
+		arc.expiry[key] = time.Now()
+		arc.dropIfExpired(key)
+	}
+	return true
+}
+
+// TTL returns the time left until the binding for key expires.
+// hasTTL is false if the binding never expires, and ok is false if key is not in the cache.
+func (arc *ARC) TTL(key string) (ttl time.Duration, hasTTL bool, ok bool) {
+	arc.dropIfExpired(key)
+	if _, inCache := arc.CheckCache(key); !inCache {
+		return 0, false, false
+	}
+	deadline, hasTTL := arc.expiry[key]
+	if !hasTTL {
+		return 0, false, true
+	}
+	return time.Until(deadline), true, true
+}
+
+// dropIfExpired drops the binding for key if its deadline has passed.
+// A dirty value is stored in the origin first, since it is the latest write.
+func (arc *ARC) dropIfExpired(key string) {
+	deadline, found := arc.expiry[key]
+	if !found || time.Now().Before(deadline) {
+		return
+	}
+	if arc.dirty[key] {
+		value, _ := arc.CheckCache(key)
+		arc.storeToOrigin(key, value)
+		delete(arc.dirty, key)
+	}
+	arc.drop(key)
+	delete(arc.expiry, key)
+}
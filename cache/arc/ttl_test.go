@@ -0,0 +1,59 @@
+package arc
+
+import (
+	"testing"
+	"time"
+)
+
+// Tests that bindings expire after their TTL and that Set clears it
+func TestARC_TTL(t *testing.T) {
+	l, err := NewARC(4, WithDirectory(t.TempDir()))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.SetWithTTL("short", []byte("1"), time.Millisecond)
+	l.SetWithTTL("long", []byte("2"), time.Hour)
+	l.Set("forever", []byte("3"))
+
+	if ttl, hasTTL, ok := l.TTL("long"); !ok || !hasTTL || ttl <= 0 || ttl > time.Hour {
+		t.Fatalf("bad ttl: %v %v %v", ttl, hasTTL, ok)
+	}
+	if _, hasTTL, ok := l.TTL("forever"); !ok || hasTTL {
+		t.Fatalf("forever should have no ttl")
+	}
+	if _, _, ok := l.TTL("missing"); ok {
+		t.Fatalf("missing key has a ttl")
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := l.Get("short"); ok {
+		t.Fatalf("short should have expired")
+	}
+	if n := l.Len(); n != 2 {
+		t.Fatalf("expired binding not dropped: %d", n)
+	}
+
+	l.Set("long", []byte("4"))
+	if _, hasTTL, _ := l.TTL("long"); hasTTL {
+		t.Fatalf("set should clear the ttl")
+	}
+
+	if !l.Expire("forever", time.Hour) {
+		t.Fatalf("expire failed")
+	}
+	if _, hasTTL, _ := l.TTL("forever"); !hasTTL {
+		t.Fatalf("expire did not set a ttl")
+	}
+	l.Expire("forever", 0)
+	if _, hasTTL, _ := l.TTL("forever"); hasTTL {
+		t.Fatalf("expire with zero should clear the ttl")
+	}
+	l.Expire("forever", -1)
+	if _, ok := l.Get("forever"); ok {
+		t.Fatalf("expire with a negative ttl should drop the binding")
+	}
+	if l.Expire("missing", time.Hour) {
+		t.Fatalf("expire of a missing key succeeded")
+	}
+}
@@ -0,0 +1,84 @@
+package arc
+
+import (
+	"errors"
+)
+
+// Default sizes of 2Q's queues, as fractions of the cache,
+// from Johnson and Shasha's recommendations.
+const (
+	defaultKin  = 0.25
+	defaultKout = 0.5
+)
+
+// twoQueuePolicy is 2Q, as Johnson and Shasha describe it.
+// New keys enter A1in, a FIFO, and are evicted from it without being promoted
+// however often they are used there, so a scan passes through without disturbing Am.
+// Keys evicted from A1in are remembered in A1out, a FIFO of ghosts, and a key
+// requested again while in A1out has proved itself and enters Am, an LRU.
+// Unlike ARC, the sizes of the queues are fixed.
+type twoQueuePolicy struct {
+	a1in  *LRU
+	a1out *LRU
+	am    *LRU
+	// Target size of A1in.
+	kin int
+}
+
+// NewTwoQueue returns a cache with room for limit entries managed by 2Q,
+// where kin is the share of the cache given to A1in, and kout the size of A1out
+// as a multiple of the cache. The "2q" policy uses 0.25 and 0.5.
+func NewTwoQueue(limit int, kin float64, kout float64, opts ...Option) (*PolicyCache, error) {
+	if kin <= 0 || kin >= 1 || kout <= 0 {
+		return nil, errors.New("arc: 2Q needs 0 < kin < 1 and kout > 0")
+	}
+	if limit <= 0 {
+		return nil, errors.New("Capacity must be greater than zero")
+	}
+	return newPolicyCache("2q", newTwoQueuePolicy(limit, kin, kout), limit, opts)
+}
+
+func newTwoQueuePolicy(limit int, kin float64, kout float64) *twoQueuePolicy {
+	var policy twoQueuePolicy
+	policy.kin = max(int(float64(limit)*kin), 1)
+	// The queues hold one spare entry for the key being inserted until
+	// the cache asks for a victim; A1out evicts its oldest ghost as it fills.
+	policy.a1in = NewLRU(limit + 1)
+	policy.a1out = NewLRU(max(int(float64(limit)*kout), 1))
+	policy.am = NewLRU(limit + 1)
+	return &policy
+}
+
+func (policy *twoQueuePolicy) OnHit(key string) {
+	// Hits in A1in leave it where it is, so that it stays a FIFO.
+	if contains(policy.am, key) {
+		policy.am.Set(key, nil)
+	}
+}
+
+func (policy *twoQueuePolicy) OnMiss(key string) {}
+
+func (policy *twoQueuePolicy) OnInsert(key string) {
+	if contains(policy.a1out, key) {
+		policy.a1out.Remove(key)
+		policy.am.Set(key, nil)
+		return
+	}
+	policy.a1in.Set(key, nil)
+}
+
+func (policy *twoQueuePolicy) Victim() (key string, ok bool) {
+	if policy.a1in.Len() > policy.kin || policy.am.Len() == 0 {
+		if key, ok = policy.a1in.Evict(); ok {
+			policy.a1out.Set(key, nil)
+			return key, true
+		}
+	}
+	return policy.am.Evict()
+}
+
+func (policy *twoQueuePolicy) Remove(key string) {
+	policy.a1in.Remove(key)
+	policy.a1out.Remove(key)
+	policy.am.Remove(key)
+}
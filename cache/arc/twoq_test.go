@@ -0,0 +1,50 @@
+package arc
+
+import (
+	"testing"
+)
+
+// Tests that keys used only in A1in are not promoted, and ghosts in A1out are
+func TestTwoQueue_Queues(t *testing.T) {
+	policy := newTwoQueuePolicy(4, 0.25, 0.5)
+	// a is hit while in A1in, then pushed out by b..e
+	SimulatePolicy(policy, 4, []string{"a", "a", "b", "c", "d", "e"})
+	if contains(policy.am, "a") || !contains(policy.a1out, "a") {
+		t.Fatalf("a should be a ghost in A1out")
+	}
+	// Requested again from A1out, a enters Am
+	SimulatePolicy(policy, 4, []string{"a"})
+	if !contains(policy.am, "a") || contains(policy.a1out, "a") {
+		t.Fatalf("a should be in Am")
+	}
+}
+
+// Tests that 2Q's tunables are validated and that it resists scans
+func TestTwoQueue_Scan(t *testing.T) {
+	if _, err := NewTwoQueue(16, 1.5, 0.5); err == nil {
+		t.Fatalf("bad kin accepted")
+	}
+	cache, err := NewTwoQueue(16, 0.5, 1)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	var _ Cache = cache
+	if cache.MaxStorage() != 16 || cache.Policy() != "2q" {
+		t.Fatalf("bad cache")
+	}
+
+	// Scans here are twice the size of the cache at limit 1000, so A1out must
+	// remember more than that for the working set to be promoted to Am
+	trace, _ := NewTrace("scan", 100000, 10000, 1)
+	for _, limit := range []int{1000, 2500} {
+		lru, _ := Simulate("lru", limit, trace)
+		arc, _ := Simulate("arc", limit, trace)
+		for _, kout := range []float64{0.5, 2} {
+			stats := SimulatePolicy(newTwoQueuePolicy(limit, defaultKin, kout), limit, trace)
+			t.Logf("scan limit %d: lru %.4f arc %.4f 2q(kout %.1f) %.4f", limit, lru.HitRatio(), arc.HitRatio(), kout, stats.HitRatio())
+			if (limit > 1000 || kout > 1) && stats.HitRatio() <= lru.HitRatio()+0.03 {
+				t.Fatalf("2q %.4f no better than lru %.4f on scans", stats.HitRatio(), lru.HitRatio())
+			}
+		}
+	}
+}
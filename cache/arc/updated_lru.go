@@ -130,6 +130,17 @@ func (lru *LRU) Evict() (key string, ok bool) {
 	return "", ok
 }
 
+// Back returns the least recently used binding, the one Evict would remove,
+// without removing it or counting it as a use.
+func (lru *LRU) Back() (key string, value []byte, ok bool) {
+	back := lru.nodes.Back()
+	if back == nil {
+		return "", nil, false
+	}
+	key = back.Value.(string)
+	return key, lru.cache[key].bytes, true
+}
+
 // Set associates the given value with the given key, possibly evicting values
 // to make room. Returns true if the binding was added successfully, else false.
 func (lru *LRU) Set(key string, value []byte) bool {
@@ -169,6 +180,27 @@ func (lru *LRU) Set(key string, value []byte) bool {
 	return true
 }
 
+// setBack is like Set, but puts the binding at the least recently used end,
+// to be evicted first.
+func (lru *LRU) setBack(key string, value []byte) bool {
+	if !lru.Set(key, value) {
+		return false
+	}
+	lru.nodes.MoveToBack(lru.cache[key].element)
+	return true
+}
+
+// update replaces the value of key, if it exists, without counting a use.
+func (lru *LRU) update(key string, value []byte) (ok bool) {
+	old_val, found := lru.cache[key]
+	if !found {
+		return false
+	}
+	old_val.bytes = value
+	lru.cache[key] = old_val
+	return true
+}
+
 // Len returns the number of bindings in the LRU.
 func (lru *LRU) Len() int {
 	return lru.usedEntries
@@ -0,0 +1,75 @@
+// Command arcd serves an ARC cache over the memcached text protocol,
+// the Redis serialization protocol or HTTP.
+//
+// Usage:
+//
+//	arcd [-protocol memcache|redis|http] [-listen addr] [-limit entries] [-dir directory]
+package main
+
+import (
+	"flag"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/andresblancobonilla/ARC_Cache_Project/cache/arc"
+)
+
+// A server is a protocol front-end for the cache.
+type server interface {
+	Serve(listener net.Listener) error
+	Close() error
+}
+
+func main() {
+	protocol := flag.String("protocol", "memcache", "protocol to serve: memcache, redis or http")
+	listen := flag.String("listen", "", "address to accept connections on (default :11211 for memcache, :6379 for redis, :8080 for http)")
+	limit := flag.Int("limit", 65536, "maximum number of entries in the cache")
+	dir := flag.String("dir", "arcd_cache", "directory for the on-disk cache directory")
+	flag.Parse()
+
+	cache, err := arc.NewSyncARC(*limit, arc.WithDirectory(*dir))
+	if err != nil {
+		log.Fatalf("arcd: %v", err)
+	}
+	var srv server
+	switch *protocol {
+	case "memcache":
+		srv = arc.NewMemcacheServer(cache)
+		if *listen == "" {
+			*listen = ":11211"
+		}
+	case "redis":
+		srv = arc.NewRESPServer(cache)
+		if *listen == "" {
+			*listen = ":6379"
+		}
+	case "http":
+		srv = &http.Server{Handler: arc.NewHTTPHandler(cache)}
+		if *listen == "" {
+			*listen = ":8080"
+		}
+	default:
+		log.Fatalf("arcd: unknown protocol %q", *protocol)
+	}
+	listener, err := net.Listen("tcp", *listen)
+	if err != nil {
+		log.Fatalf("arcd: %v", err)
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		srv.Close()
+	}()
+
+	log.Printf("arcd: serving %s protocol on %v", *protocol, listener.Addr())
+	if err := srv.Serve(listener); err != nil && err != arc.ErrServerClosed && err != http.ErrServerClosed {
+		log.Fatalf("arcd: %v", err)
+	}
+	cache.Close()
+}
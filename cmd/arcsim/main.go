@@ -0,0 +1,67 @@
+// Command arcsim replays a trace of keys against cache replacement policies
+// and reports the hit ratio of each.
+//
+// Usage:
+//
+//	arcsim [-policies lru,arc,...] [-limit entries] [-trace rand|zipf|loop|scan] [-length n] [-keys n] [-seed n] [file]
+//
+// A file, if given, holds one key per line and replaces the synthetic trace.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/andresblancobonilla/ARC_Cache_Project/cache/arc"
+)
+
+func main() {
+	names := flag.String("policies", strings.Join(arc.PolicyNames(), ","), "comma-separated policies to compare")
+	limit := flag.Int("limit", 1000, "maximum number of entries in the cache")
+	kind := flag.String("trace", "zipf", "synthetic trace: "+strings.Join(arc.TraceKinds, ", "))
+	length := flag.Int("length", 1000000, "number of lookups in the synthetic trace")
+	keys := flag.Int("keys", 10000, "number of distinct keys in the synthetic trace")
+	seed := flag.Int64("seed", 1, "seed for the synthetic trace")
+	flag.Parse()
+
+	var trace []string
+	var err error
+	if flag.NArg() > 0 {
+		trace, err = readTrace(flag.Arg(0))
+	} else {
+		trace, err = arc.NewTrace(*kind, *length, *keys, *seed)
+	}
+	if err != nil {
+		log.Fatalf("arcsim: %v", err)
+	}
+
+	fmt.Printf("%-10s %10s %10s %8s\n", "policy", "hits", "misses", "ratio")
+	for _, name := range strings.Split(*names, ",") {
+		stats, err := arc.Simulate(name, *limit, trace)
+		if err != nil {
+			log.Fatalf("arcsim: %v", err)
+		}
+		fmt.Printf("%-10s %10d %10d %8.4f\n", name, stats.Hits, stats.Misses, stats.HitRatio())
+	}
+}
+
+// readTrace reads one key per line from the file at path.
+func readTrace(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var trace []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if key := strings.TrimSpace(scanner.Text()); key != "" {
+			trace = append(trace, key)
+		}
+	}
+	return trace, scanner.Err()
+}
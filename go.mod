@@ -0,0 +1,3 @@
+module github.com/andresblancobonilla/ARC_Cache_Project
+
+go 1.23